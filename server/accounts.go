@@ -0,0 +1,299 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNicknameTaken = errors.New("nickname is already registered")
+	errAuthFailed    = errors.New("invalid nickname, password or token")
+)
+
+// Account reserves nickname for player who knows its password or one of its tokens.
+// Tokens are stored as hex encoded SHA-256 hashes, so pre-shared tokens can be added to accounts file by hand
+type Account struct {
+	Nickname     string    `json:"nickname"`
+	Salt         string    `json:"salt,omitempty"` // Hex encoded
+	PasswordHash string    `json:"passwordHash,omitempty"`
+	Iterations   int       `json:"iterations,omitempty"`
+	TokenHashes  []string  `json:"tokenHashes,omitempty"` // Oldest first
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// AccountsStore keeps accounts by lowercased nickname in JSON file, so nicknames differing only in case are reserved too.
+// Empty path keeps them in memory only
+type AccountsStore struct {
+	mtx      sync.Mutex
+	path     string
+	accounts map[string]*Account
+}
+
+func newAccountsStore(path string) (*AccountsStore, error) {
+	store := &AccountsStore{
+		path:     path,
+		accounts: map[string]*Account{},
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	var accounts []*Account
+	if err := loadJSONFile(path, &accounts); err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		store.accounts[accountKey(account.Nickname)] = account
+	}
+	return store, nil
+}
+
+func accountKey(nickname string) string {
+	return strings.ToLower(nickname)
+}
+
+func (store *AccountsStore) registered(nickname string) bool {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+
+	_, ok := store.accounts[accountKey(nickname)]
+	return ok
+}
+
+// register creates account and returns a token which can be used to log in instead of password
+func (store *AccountsStore) register(nickname string, password string) (string, error) {
+	// Hashing is slow on purpose, so it's done without holding the lock
+	salt := randomHex(PASSWORD_SALT_SIZE)
+	passwordHash := hashPassword(password, salt, PASSWORD_HASH_ITERATIONS)
+	token := randomHex(AUTH_TOKEN_SIZE)
+
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+
+	key := accountKey(nickname)
+	if _, ok := store.accounts[key]; ok {
+		return "", errNicknameTaken
+	}
+	store.accounts[key] = &Account{
+		Nickname:     nickname,
+		Salt:         salt,
+		PasswordHash: passwordHash,
+		Iterations:   PASSWORD_HASH_ITERATIONS,
+		TokenHashes:  []string{hashToken(token)},
+		CreatedAt:    time.Now(),
+	}
+	return token, store.save()
+}
+
+// login checks password or token (the one which is not empty) and returns account nickname and token to log in next time.
+// Password login issues a new token, token login returns the same token
+func (store *AccountsStore) login(nickname string, password string, token string) (string, string, error) {
+	store.mtx.Lock()
+	account, ok := store.accounts[accountKey(nickname)]
+	var copied Account
+	if ok {
+		copied = *account
+	}
+	store.mtx.Unlock()
+	if !ok || (password == "") == (token == "") {
+		return "", "", errAuthFailed
+	}
+
+	if token != "" {
+		hash := hashToken(token)
+		for _, tokenHash := range copied.TokenHashes {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(tokenHash)) == 1 {
+				return copied.Nickname, token, nil
+			}
+		}
+		return "", "", errAuthFailed
+	}
+
+	if copied.PasswordHash == "" { // Token only account
+		return "", "", errAuthFailed
+	}
+	hash := hashPassword(password, copied.Salt, copied.Iterations)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(copied.PasswordHash)) != 1 {
+		return "", "", errAuthFailed
+	}
+
+	token = randomHex(AUTH_TOKEN_SIZE)
+
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+	account.TokenHashes = append(account.TokenHashes, hashToken(token))
+	if len(account.TokenHashes) > MAX_AUTH_TOKENS_COUNT {
+		account.TokenHashes = account.TokenHashes[len(account.TokenHashes)-MAX_AUTH_TOKENS_COUNT:]
+	}
+	return account.Nickname, token, store.save()
+}
+
+func (store *AccountsStore) save() error {
+	if store.path == "" {
+		return nil
+	}
+
+	accounts := make([]*Account, 0, len(store.accounts))
+	for _, account := range store.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Nickname < accounts[j].Nickname })
+
+	return saveJSONFile(store.path, accounts)
+}
+
+func randomHex(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func hashPassword(password string, salt string, iterations int) string {
+	return hex.EncodeToString(pbkdf2([]byte(password), []byte(salt), iterations, sha256.Size))
+}
+
+// pbkdf2 derives key from password using HMAC-SHA256 as described in RFC 8018
+func pbkdf2(password []byte, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocksCount := (keyLen + prf.Size() - 1) / prf.Size()
+
+	key := make([]byte, 0, blocksCount*prf.Size())
+	blockIndex := make([]byte, 4)
+	var u []byte
+	for block := 1; block <= blocksCount; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(blockIndex, uint32(block))
+		prf.Write(blockIndex)
+		u = prf.Sum(u[:0])
+
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// useNickname sets nickname player has sent in handshake, sending typed failure event if it can't be used.
+// Authenticated players always play under their account nickname
+func (pl *Player) useNickname(nickname string) bool {
+	if pl.authenticated {
+		return true
+	}
+	if pl.cfg.RequireAuth {
+		pl.send(AUTH_REQUIRED, nil)
+		return false
+	}
+	if !pl.cfg.isValidNickname(nickname) {
+		pl.send(INVALID_NICKNAME, nil)
+		return false
+	}
+	if ACCOUNTS_STORE.registered(nickname) {
+		pl.send(NICKNAME_TAKEN, nil)
+		return false
+	}
+	pl.name = nickname
+	return true
+}
+
+// register creates account and logs player in, sending typed failure event if it can't be done.
+// Every registration counts against the limit of remote address, so accounts can't be mass created
+// and taken nicknames can't be probed
+func (pl *Player) register(nickname string, password string) bool {
+	if retryAfter := REGISTER_LIMITER.retryAfter(pl.remoteAddr); retryAfter != 0 {
+		pl.sendTooManyAttempts(retryAfter)
+		return false
+	}
+	if !pl.cfg.isValidNickname(nickname) {
+		pl.send(INVALID_NICKNAME, nil)
+		return false
+	}
+	if len(password) < MIN_PASSWORD_LEN || len(password) > MAX_PASSWORD_LEN {
+		pl.send(INVALID_PASSWORD, nil)
+		return false
+	}
+
+	REGISTER_LIMITER.fail(pl.remoteAddr)
+	token, err := ACCOUNTS_STORE.register(nickname, password)
+	if errors.Is(err, errNicknameTaken) {
+		pl.send(NICKNAME_TAKEN, nil)
+		return false
+	}
+	if err != nil {
+		pl.logInfo("Could not save accounts: %s", err)
+	}
+
+	pl.name = nickname
+	pl.authenticated = true
+	pl.logInfo("Registered account %s", pl.name)
+	pl.send(REGISTER, StocAuthenticated{
+		Nickname: pl.name,
+		Token:    token,
+	})
+	return true
+}
+
+// logIn authenticates player with password or token, sending typed failure event if it can't be done.
+// Failed attempts are limited per remote address, so passwords can't be brute forced
+func (pl *Player) logIn(nickname string, password string, token string) bool {
+	if retryAfter := LOGIN_LIMITER.retryAfter(pl.remoteAddr); retryAfter != 0 {
+		pl.sendTooManyAttempts(retryAfter)
+		return false
+	}
+
+	name, token, err := ACCOUNTS_STORE.login(nickname, password, token)
+	if errors.Is(err, errAuthFailed) {
+		pl.logInfo("Failed login attempt as %s", nickname)
+		LOGIN_LIMITER.fail(pl.remoteAddr)
+		pl.send(AUTH_FAILED, nil)
+		return false
+	}
+	if err != nil {
+		pl.logInfo("Could not save accounts: %s", err)
+	}
+
+	pl.name = name
+	pl.authenticated = true
+	pl.logInfo("Logged in as %s", pl.name)
+	pl.send(LOGIN, StocAuthenticated{
+		Nickname: pl.name,
+		Token:    token,
+	})
+	return true
+}
+
+func (pl *Player) sendTooManyAttempts(retryAfter time.Duration) {
+	pl.send(TOO_MANY_ATTEMPTS, StocTooManyAttempts{
+		RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+	})
+}
+
+var LOGIN_LIMITER = newAttemptsLimiter(MAX_LOGIN_ATTEMPTS, LOGIN_ATTEMPTS_WINDOW, systemClock{})
+var REGISTER_LIMITER = newAttemptsLimiter(MAX_REGISTRATIONS, REGISTRATIONS_WINDOW, systemClock{})
+
+// Replaced with file backed store in main()
+var ACCOUNTS_STORE, _ = newAccountsStore("")
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPbkdf2(t *testing.T) {
+	// Test vectors from RFC 7914
+	key := pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if hex.EncodeToString(key) != expected {
+		t.Errorf("Unexpected derived key: %x", key)
+	}
+
+	key = pbkdf2([]byte("password"), []byte("salt"), 4096, 32)
+	expected = "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"
+	if hex.EncodeToString(key) != expected {
+		t.Errorf("Unexpected derived key: %x", key)
+	}
+}
+
+func TestAccountsStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	store, err := newAccountsStore(path)
+	if err != nil {
+		t.Fatalf("Could not create accounts store: %s", err)
+	}
+
+	token, err := store.register("Admiral", "secret123")
+	if err != nil || token == "" {
+		t.Fatalf("Could not register account: %s", err)
+	}
+	if _, err := store.register("admiral", "another123"); err != errNicknameTaken {
+		t.Errorf("Nickname differing only in case was registered again")
+	}
+
+	store, err = newAccountsStore(path)
+	if err != nil {
+		t.Fatalf("Could not load accounts store: %s", err)
+	}
+
+	nickname, newToken, err := store.login("admiral", "secret123", "")
+	if err != nil || nickname != "Admiral" || newToken == token {
+		t.Errorf("Could not log in with password: %s", err)
+	}
+	if nickname, _, err := store.login("Admiral", "", token); err != nil || nickname != "Admiral" {
+		t.Errorf("Could not log in with token: %s", err)
+	}
+
+	for _, attempt := range []struct{ nickname, password, token string }{
+		{"Admiral", "wrong123", ""},
+		{"Admiral", "", "wrong"},
+		{"Admiral", "secret123", token},
+		{"Admiral", "", ""},
+		{"Nobody", "secret123", ""},
+	} {
+		if _, _, err := store.login(attempt.nickname, attempt.password, attempt.token); err != errAuthFailed {
+			t.Errorf("Logged in with invalid credentials: %+v", attempt)
+		}
+	}
+}
+
+func TestUseNickname(t *testing.T) {
+	cfg := newTestConfig(t)
+	store := ACCOUNTS_STORE
+	defer func() { ACCOUNTS_STORE = store }()
+	ACCOUNTS_STORE, _ = newAccountsStore("")
+
+	if _, err := ACCOUNTS_STORE.register("Registered", "secret123"); err != nil {
+		t.Fatalf("Could not register account: %s", err)
+	}
+
+	if newTestPlayer(cfg, "").useNickname("Registered") {
+		t.Errorf("Registered nickname was used without authentication")
+	}
+	if player := newTestPlayer(cfg, ""); !player.useNickname("Guest") || player.name != "Guest" {
+		t.Errorf("Free nickname was not used")
+	}
+
+	cfg.RequireAuth = true
+	if newTestPlayer(cfg, "").useNickname("Guest") {
+		t.Errorf("Nickname was used without authentication while it is required")
+	}
+	player := newTestPlayer(cfg, "Registered")
+	player.authenticated = true
+	if !player.useNickname("Other") || player.name != "Registered" {
+		t.Errorf("Authenticated player does not keep his account nickname")
+	}
+}
+
+func TestLoginLimit(t *testing.T) {
+	cfg := newTestConfig(t)
+	store, limiter := ACCOUNTS_STORE, LOGIN_LIMITER
+	defer func() { ACCOUNTS_STORE, LOGIN_LIMITER = store, limiter }()
+	clock := &fakeClock{now: time.Now()}
+	ACCOUNTS_STORE, _ = newAccountsStore("")
+	LOGIN_LIMITER = newAttemptsLimiter(2, time.Minute, clock)
+
+	if _, err := ACCOUNTS_STORE.register("Registered", "secret123"); err != nil {
+		t.Fatalf("Could not register account: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if newTestPlayer(cfg, "127.0.0.1:1000").logIn("Registered", "wrong123", "") {
+			t.Fatalf("Logged in with wrong password")
+		}
+	}
+	player := newTestPlayer(cfg, "127.0.0.1:2000")
+	if player.logIn("Registered", "secret123", "") || player.authenticated {
+		t.Errorf("Password was checked after too many failed logins")
+	}
+	if !newTestPlayer(cfg, "127.0.0.2:1000").logIn("Registered", "secret123", "") {
+		t.Errorf("Another address was blocked")
+	}
+
+	clock.advance(time.Minute)
+	if !player.logIn("Registered", "secret123", "") || !player.authenticated || player.name != "Registered" {
+		t.Errorf("Address was not unblocked after window is over")
+	}
+}
+
+func TestRegisterLimit(t *testing.T) {
+	cfg := newTestConfig(t)
+	store, limiter := ACCOUNTS_STORE, REGISTER_LIMITER
+	defer func() { ACCOUNTS_STORE, REGISTER_LIMITER = store, limiter }()
+	clock := &fakeClock{now: time.Now()}
+	ACCOUNTS_STORE, _ = newAccountsStore("")
+	REGISTER_LIMITER = newAttemptsLimiter(2, time.Minute, clock)
+
+	if !newTestPlayer(cfg, "127.0.0.1:1000").register("First", "secret123") {
+		t.Fatalf("Could not register account")
+	}
+	if newTestPlayer(cfg, "127.0.0.1:2000").register("First", "secret123") {
+		t.Fatalf("Taken nickname was registered")
+	}
+	if newTestPlayer(cfg, "127.0.0.1:3000").register("Second", "secret123") || ACCOUNTS_STORE.registered("Second") {
+		t.Errorf("Account was registered after too many registrations")
+	}
+	if !newTestPlayer(cfg, "127.0.0.2:1000").register("Second", "secret123") {
+		t.Errorf("Another address was blocked")
+	}
+
+	clock.advance(time.Minute)
+	if !newTestPlayer(cfg, "127.0.0.1:3000").register("Third", "secret123") {
+		t.Errorf("Address was not unblocked after window is over")
+	}
+}
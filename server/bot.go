@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"time"
+)
+
+func newBotPlayer(cfg *Config, difficulty BotDifficulty) (*Player, error) {
+	bot, err := newStrategy(difficulty)
+	if err != nil {
+		return nil, err
+	}
+	return &Player{
+		cfg:         cfg,
+		name:        fmt.Sprintf("Bot (%s)", difficulty),
+		remoteAddr:  "bot",
+		connectedAt: time.Now(),
+		bot:         bot,
+	}, nil
+}
+
+// placeRandomFleet places the whole fleet of player at random legal positions
+func (pl *Player) placeRandomFleet() error {
+	types := pl.rules().placeableTypes()
+	sort.SliceStable(types, func(i, j int) bool { // The biggest ships are the hardest to place
+		a, _ := pl.rules().entityRule(types[i])
+		b, _ := pl.rules().entityRule(types[j])
+		return a.Size.x*a.Size.y > b.Size.x*b.Size.y
+	})
+
+	budget := MAX_RANDOM_TOTAL_ATTEMPTS
+	for attempt := 0; attempt < MAX_RANDOM_FLEET_ATTEMPTS && budget > 0; attempt++ {
+		pl.clearEntities()
+		if pl.tryPlaceRandomFleet(types, &budget) {
+			return nil
+		}
+	}
+	pl.clearEntities()
+	return errors.New("could not place fleet at random")
+}
+
+// tryPlaceRandomFleet makes a single attempt to place the fleet, every entity attempt is taken from budget
+func (pl *Player) tryPlaceRandomFleet(types []EntityType, budget *int) bool {
+	rules := pl.rules()
+	for _, type_ := range types {
+		for pl.availableEntityTypeCount(type_) > 0 {
+			placed := false
+			for attempt := 0; attempt < MAX_RANDOM_ENTITY_ATTEMPTS && *budget > 0 && !placed; attempt++ {
+				*budget--
+				position := Vec2{x: 1 + rand.Intn(rules.Width), y: 1 + rand.Intn(rules.Height)}
+				directions := rules.directions(type_)
+				direction := directions[rand.Intn(len(directions))]
+				entity, err := newEntity(rules, type_, position, direction)
+				placed = err == nil && pl.addEntity(entity) == nil
+			}
+			if !placed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkFleetPlacement makes bot place fleet on a scratch battlefield, so rooms where it can't do that are rejected
+// before they get stuck in building stage
+func (pl *Player) checkFleetPlacement(rules Rules) error {
+	board := newOfflineRoom(pl.cfg, rules, pl.name, "").primary
+	board.bot = pl.bot
+	board.bot.newGame(board.rules())
+	return board.placeBotFleet()
+}
+
+// placeBotFleet adds fleet chosen by bot strategy to battlefield of player, checking it as a fleet of human player
+func (pl *Player) placeBotFleet() error {
+	entities, err := pl.bot.placeFleet(pl.rules())
+	if err != nil {
+		return err
+	}
+
+	pl.clearEntities()
+	for _, entity := range entities {
+		if err := pl.addEntity(entity); err != nil {
+			pl.clearEntities()
+			return err
+		}
+	}
+	if !pl.built() {
+		pl.clearEntities()
+		return errors.New("not enough entities were placed")
+	}
+	return nil
+}
+
+// vsBot reports if room has a bot player. Such games are not counted in statistics
+func (room *Room) vsBot() bool {
+	for _, player := range room.players() {
+		if player.bot != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareBots places fleets of bots when building stage starts. Room mutex has to be locked
+func (room *Room) prepareBots() {
+	for _, player := range room.players() {
+		if player.bot == nil {
+			continue
+		}
+
+		player.bot.newGame(player.rules())
+		if err := player.placeBotFleet(); err != nil {
+			room.logInfo("%s could not place fleet: %s", player.name, err)
+			continue
+		}
+		player.announceReady()
+	}
+}
+
+// scheduleBotMove makes bot shoot after a short delay if it's his turn. Room mutex has to be locked
+func (room *Room) scheduleBotMove() {
+	bot := room.player(room.turn)
+	if !room.valid() || !room.playing() || bot == nil || bot.bot == nil {
+		return
+	}
+
+	shots := bot.shots
+	time.AfterFunc(room.cfg.BotMoveDelay, func() {
+		room.mtx.Lock()
+		defer room.mtx.Unlock()
+
+		// Move could be already made on behalf of bot or game could be over meanwhile
+		if !room.valid() || !room.playing() || room.turn != bot.role || bot.room != room || bot.shots != shots {
+			return
+		}
+		room.botMove(bot)
+	})
+}
+
+// botMove makes bot shoot once or fire the whole salvo and reports if strategy has chosen a valid move.
+// Otherwise bot shoots at random, or passes the turn if there is nothing to shoot at, so the game goes on.
+// Room mutex has to be locked
+func (room *Room) botMove(bot *Player) bool {
+	if room.strategyMove(bot) {
+		return true
+	}
+
+	if room.rules.Salvo {
+		if points := bot.fillSalvo(nil); room.validateSalvo(bot, points) == nil {
+			room.fireSalvo(bot, points)
+			return false
+		}
+	} else if point, ok := bot.enemy().randomNotShotPoint(); ok && room.fire(bot, point) != SHOT_INVALID {
+		return false
+	}
+	room.logInfo("%s passes the turn", bot.name)
+	room.switchTurn()
+	return false
+}
+
+// strategyMove makes move chosen by bot strategy, nothing is done if the move is invalid
+func (room *Room) strategyMove(bot *Player) bool {
+	if !room.rules.Salvo {
+		point, ok := bot.bot.chooseShot()
+		if !ok {
+			room.logInfo("%s has no point to shoot at", bot.name)
+			return false
+		}
+		if room.fire(bot, point) == SHOT_INVALID {
+			room.logInfo("%s has chosen invalid shot at %d:%d", bot.name, point.x, point.y)
+			return false
+		}
+		return true
+	}
+
+	// Strategy learns results only after the salvo, so it may choose the same point again
+	var points []Vec2
+	for attempt, size := 0, bot.salvoSize(); attempt < size; attempt++ {
+		if point, ok := bot.bot.chooseShot(); ok && !slices.Contains(points, point) {
+			points = append(points, point)
+		}
+	}
+	points = bot.fillSalvo(points)
+	if err := room.validateSalvo(bot, points); err != nil {
+		room.logInfo("%s has chosen invalid salvo: %s", bot.name, err)
+		return false
+	}
+	room.fireSalvo(bot, points)
+	return true
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRandomFleet(t *testing.T) {
+	cfg := newTestConfig(t)
+	rules := defaultRules()
+	tight := Rules{Width: 7, Height: 7, Fleet: map[EntityType]ShipRule{
+		FOURDECK:   {Size: Vec2{x: 4, y: 1}, Count: 1},
+		SINGLEDECK: {Size: Vec2{x: 1, y: 1}, Count: 5},
+	}}
+	if err := tight.validate(); err != nil {
+		t.Fatalf("Test rules are invalid: %s", err)
+	}
+
+	for _, rules := range []Rules{rules, tight} {
+		for i := 0; i < 20; i++ {
+			player := newOfflineRoom(cfg, rules, "primary", "secondary").primary
+			if err := player.placeRandomFleet(); err != nil || !player.built() {
+				t.Fatalf("Could not place random fleet: %s", err)
+			}
+		}
+	}
+}
+
+// playBotGame makes bot shoot at random fleet until it's sunk and returns shots count
+func playBotGame(t *testing.T, cfg *Config, difficulty BotDifficulty) int {
+	room := newOfflineRoom(cfg, defaultRules(), "primary", "secondary")
+	if err := room.secondary.placeRandomFleet(); err != nil {
+		t.Fatalf("Could not place random fleet: %s", err)
+	}
+	room.gamestate = PLAYING
+
+	bot, err := newStrategy(difficulty)
+	if err != nil {
+		t.Fatalf("Could not create bot: %s", err)
+	}
+	room.primary.bot = bot
+	bot.newGame(&room.rules)
+
+	for shots := 1; shots <= room.rules.Width*room.rules.Height; shots++ {
+		if !room.botMove(room.primary) {
+			t.Fatalf("Bot %s has made invalid shot", difficulty)
+		}
+		if room.over() {
+			return shots
+		}
+	}
+	t.Fatalf("Bot %s has not sunk the fleet", difficulty)
+	return 0
+}
+
+func TestBotDifficulties(t *testing.T) {
+	cfg := newTestConfig(t)
+	const games = 50
+
+	// Averages are random, so every difficulty is kept within loose bounds instead of comparing them to each other
+	bounds := []struct {
+		difficulty BotDifficulty
+		min        float64
+		max        float64
+	}{
+		{BOT_RANDOM, 60, 80},
+		{BOT_HUNT_TARGET, 50, 63},
+		{BOT_PROBABILITY, 48, 60},
+	}
+	for _, bound := range bounds {
+		total := 0
+		for i := 0; i < games; i++ {
+			total += playBotGame(t, cfg, bound.difficulty)
+		}
+		if average := float64(total) / games; average < bound.min || average > bound.max {
+			t.Errorf("Bot %s has sunk the fleet in %.1f shots on average, expected %.0f..%.0f", bound.difficulty, average, bound.min, bound.max)
+		}
+	}
+}
+
+func TestAiRoom(t *testing.T) {
+	cfg := newTestConfig(t)
+	bot, err := newBotPlayer(cfg, BOT_HUNT_TARGET)
+	if err != nil {
+		t.Fatalf("Could not create bot: %s", err)
+	}
+	if _, err := newBotPlayer(cfg, "genius"); err == nil {
+		t.Errorf("Bot with unknown difficulty was created")
+	}
+
+	room := createRoom(newTestPlayer(cfg, "primary"), defaultRules())
+	defer room.destroy()
+	room.addPlayer(bot)
+	if !bot.built() {
+		t.Fatalf("Bot has not placed his fleet")
+	}
+
+	placeTestFleet(t, room.primary)
+	if !room.startPlaying() {
+		t.Fatalf("Could not start playing against bot")
+	}
+	if !room.vsBot() {
+		t.Errorf("Room with bot is not reported as such")
+	}
+}
+
+// readyToPlayTestFleet returns READY_TO_PLAY request with fleet of placeTestFleet
+func readyToPlayTestFleet(t *testing.T, cfg *Config) *CtosReadyToPlay {
+	player := newOfflineRoom(cfg, defaultRules(), "primary", "secondary").primary
+	placeTestFleet(t, player)
+
+	var entities []ReplayEntity // Same fields as entities of READY_TO_PLAY
+	for _, entity := range player.entities {
+		entities = append(entities, ReplayEntity{
+			Type_:     entity.type_,
+			Position:  StocPoint{X: entity.position.x, Y: entity.position.y},
+			Direction: entity.direction,
+		})
+	}
+	payload, err := json.Marshal(map[string]any{"entities": entities})
+	if err != nil {
+		t.Fatalf("Could not encode fleet: %s", err)
+	}
+	data := &CtosReadyToPlay{}
+	if err := json.Unmarshal(payload, data); err != nil {
+		t.Fatalf("Could not decode fleet: %s", err)
+	}
+	return data
+}
+
+func TestAutoPlace(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := createRoom(newTestPlayer(cfg, "primary"), defaultRules())
+	defer room.destroy()
+	room.addPlayer(newTestPlayer(cfg, "secondary"))
+	primary, secondary := room.primary, room.secondary
+
+	primary.readyToPlay(&CtosReadyToPlay{})
+	if primary.ready {
+		t.Fatalf("Player is ready without any fleet")
+	}
+
+	primary.autoPlace()
+	if !primary.built() || primary.ready || room.startPlaying() {
+		t.Fatalf("Auto placed fleet was confirmed without READY_TO_PLAY")
+	}
+	primary.readyToPlay(&CtosReadyToPlay{}) // Confirms fleet placed on the server
+	if !primary.ready || !primary.built() {
+		t.Fatalf("Auto placed fleet was not confirmed")
+	}
+
+	// Fleet sent with READY_TO_PLAY replaces the one placed on the server
+	secondary.autoPlace()
+	secondary.readyToPlay(readyToPlayTestFleet(t, cfg))
+	if !secondary.ready || !secondary.built() {
+		t.Fatalf("Sent fleet was not accepted after auto placement")
+	}
+	if entity := secondary.entityAt(Vec2{x: 1, y: 1}); entity == nil || entity.type_ != FOURDECK {
+		t.Errorf("Sent fleet has not replaced auto placed one")
+	}
+	if !room.playing() {
+		t.Errorf("Game has not started once both fleets were confirmed")
+	}
+}
+
+func TestShapeBots(t *testing.T) {
+	rules := newShapeRules(t)
+	for _, difficulty := range []BotDifficulty{BOT_HUNT_TARGET, BOT_PROBABILITY} {
+		for i := 0; i < 10; i++ {
+			if _, err := playOfflineGame(newTestConfig(t), rules, difficulty, BOT_RANDOM); err != nil {
+				t.Fatalf("Could not play game with shapes: %s", err)
+			}
+		}
+	}
+}
+
+// stuckStrategy never finds a point to shoot at
+type stuckStrategy struct {
+	botKnowledge
+}
+
+func (bot *stuckStrategy) chooseShot() (Vec2, bool) {
+	return Vec2{}, false
+}
+
+// placeFleet places the same ship twice
+func (bot *stuckStrategy) placeFleet(rules *Rules) ([]Entity, error) {
+	entity, err := newEntity(rules, SINGLEDECK, Vec2{x: 1, y: 1}, HORIZONTAL)
+	return []Entity{entity, entity}, err
+}
+
+func TestStuckBot(t *testing.T) {
+	cfg := newTestConfig(t)
+	if _, ok := randomPoint(nil); ok {
+		t.Errorf("Random point was chosen from empty list")
+	}
+
+	room := newOfflineRoom(cfg, defaultRules(), "primary", "secondary")
+	if err := room.secondary.placeRandomFleet(); err != nil {
+		t.Fatalf("Could not place random fleet: %s", err)
+	}
+	room.gamestate = PLAYING
+	room.turn = PRIMARY
+	room.primary.bot = &stuckStrategy{}
+	room.primary.bot.newGame(&room.rules)
+
+	if room.botMove(room.primary) {
+		t.Errorf("Move of stuck bot is reported as valid")
+	}
+	if room.primary.shots != 1 {
+		t.Errorf("Stuck bot has not shot at random")
+	}
+
+	// Nothing is left to shoot at, so the turn is passed
+	for _, point := range room.secondary.notShotPoints() {
+		room.secondary.shotAt(point)
+	}
+	room.turn = PRIMARY
+	room.botMove(room.primary)
+	if room.turn != SECONDARY {
+		t.Errorf("Stuck bot has not passed the turn")
+	}
+}
+
+func TestUnplaceableBotFleet(t *testing.T) {
+	cfg := newTestConfig(t)
+	bot, err := newBotPlayer(cfg, BOT_RANDOM)
+	if err != nil {
+		t.Fatalf("Could not create bot: %s", err)
+	}
+
+	// Fits by area, but every cell left is next to the big ship
+	rules := Rules{Width: 5, Height: 5, Fleet: map[EntityType]ShipRule{
+		FOURDECK:   {Size: Vec2{x: 4, y: 4}, Count: 1},
+		SINGLEDECK: {Size: Vec2{x: 1, y: 1}, Count: 1},
+	}}
+	if err := rules.validate(); err != nil {
+		t.Fatalf("Test rules are invalid: %s", err)
+	}
+	if bot.checkFleetPlacement(rules) == nil {
+		t.Errorf("Bot has placed fleet which can't be placed")
+	}
+	if err := bot.checkFleetPlacement(defaultRules()); err != nil {
+		t.Errorf("Bot could not place default fleet: %s", err)
+	}
+
+	bot.bot = &stuckStrategy{}
+	if bot.checkFleetPlacement(defaultRules()) == nil {
+		t.Errorf("Overlapping bot fleet was accepted")
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Prefix of environment variables overriding configuration, e.g. SEABATTLE_PORT or SEABATTLE_PING_TIMEOUT
+const CONFIG_ENV_PREFIX = "SEABATTLE_"
+
+// Config holds runtime server configuration.
+// Values are taken from defaults (see constants.go), then config file, then environment variables, then command line flags
+type Config struct {
+	Host string
+	Port int
+
+	InitialTimeout        time.Duration
+	BuildingTimeout       time.Duration
+	GameplayTimeout       time.Duration
+	RevengeRequestTimeout time.Duration
+
+	PingTimeout      time.Duration
+	HandshakeTimeout time.Duration
+
+	MinNicknameLen          int
+	MaxNicknameLen          int
+	NicknameValidationRegex string
+	nicknameRegex           *regexp.Regexp
+
+	MinEventsInterval time.Duration
+	MaxEventsCount    int
+
+	MaxSecurityErrorsCount int
+
+	ReaperInterval      time.Duration
+	MatchmakingInterval time.Duration
+
+	ReconnectGracePeriod time.Duration
+
+	ReplayDir string
+	StatsFile string
+
+	AccountsFile string
+	RequireAuth  bool
+
+	MaxSpectators        int
+	SpectatorRevealDelay time.Duration
+
+	TurnTimeout       time.Duration
+	TurnTimeoutAction string
+	MaxMissedTurns    int
+
+	BotMoveDelay time.Duration
+}
+
+func defaultConfig() *Config {
+	cfg := &Config{}
+	cfg.flagSet()
+	cfg.validate() // Compiles nickname regex, defaults are always valid
+	return cfg
+}
+
+// flagSet binds every configuration option to a flag and resets options to default values.
+// Flag names are also used as config file keys and (uppercased) environment variables names
+func (cfg *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("seabattle", flag.ContinueOnError)
+
+	fs.String("config", "", "path to JSON configuration file")
+
+	fs.StringVar(&cfg.Host, "host", CONN_HOST, "address to listen on")
+	fs.IntVar(&cfg.Port, "port", CONN_PORT, "port to listen on")
+
+	fs.DurationVar(&cfg.InitialTimeout, "initial-timeout", MAX_INITIAL_TIMEOUT, "time to wait for the second player")
+	fs.DurationVar(&cfg.BuildingTimeout, "building-timeout", MAX_BUILDING_TIMEOUT, "time given to build battlefields")
+	fs.DurationVar(&cfg.GameplayTimeout, "gameplay-timeout", MAX_GAMEPLAY_TIMEOUT, "maximal duration of a single game")
+	fs.DurationVar(&cfg.RevengeRequestTimeout, "revenge-request-timeout", MAX_REVENGE_REQUEST_TIMEOUT, "time to request a revenge after game is over")
+
+	fs.DurationVar(&cfg.PingTimeout, "ping-timeout", MAX_PING_TIMEOUT, "time to wait for any event from player in room")
+	fs.DurationVar(&cfg.HandshakeTimeout, "handshake-timeout", MAX_HANDSHAKE_TIMEOUT, "time to wait for initial handshake")
+
+	fs.IntVar(&cfg.MinNicknameLen, "min-nickname-len", MIN_NICKNAME_LEN, "minimal nickname length")
+	fs.IntVar(&cfg.MaxNicknameLen, "max-nickname-len", MAX_NICKNAME_LEN, "maximal nickname length")
+	fs.StringVar(&cfg.NicknameValidationRegex, "nickname-validation-regex", NICKNAME_VALIDATION_REGEX, "regular expression nickname has to match")
+
+	fs.DurationVar(&cfg.MinEventsInterval, "min-events-interval", MIN_EVENTS_INTERVAL, "antiflood: events sent within this interval are counted")
+	fs.IntVar(&cfg.MaxEventsCount, "max-events-count", MAX_EVENTS_COUNT, "antiflood: kick after this many counted events")
+
+	fs.IntVar(&cfg.MaxSecurityErrorsCount, "max-security-errors-count", MAX_SECURITY_ERRORS_COUNT, "kick after this many security errors, 0 = unlimited")
+
+	fs.DurationVar(&cfg.TurnTimeout, "turn-timeout", MAX_TURN_TIMEOUT, "shot clock: time given to make a move, 0 = disabled")
+	fs.StringVar(&cfg.TurnTimeoutAction, "turn-timeout-action", TURN_TIMEOUT_ACTION, "shot clock: \"shot\" to shoot at random cell or \"pass\" to pass the turn")
+	fs.IntVar(&cfg.MaxMissedTurns, "max-missed-turns", MAX_MISSED_TURNS, "shot clock: forfeit after this many turns in a row were missed, 0 = never")
+
+	fs.DurationVar(&cfg.BotMoveDelay, "bot-move-delay", BOT_MOVE_DELAY, "how long bot waits before each shot")
+
+	fs.DurationVar(&cfg.ReconnectGracePeriod, "reconnect-grace-period", RECONNECT_GRACE_PERIOD, "time room keeps slot of player who lost connection, 0 = disabled")
+
+	fs.StringVar(&cfg.ReplayDir, "replay-dir", REPLAY_DIR, "directory finished games replays are saved to, empty = replays are disabled")
+
+	fs.StringVar(&cfg.StatsFile, "stats-file", STATS_FILE, "file players statistics are kept in, empty = statistics are kept in memory only")
+
+	fs.StringVar(&cfg.AccountsFile, "accounts-file", ACCOUNTS_FILE, "file players accounts are kept in, empty = accounts are kept in memory only")
+	fs.BoolVar(&cfg.RequireAuth, "require-auth", REQUIRE_AUTH, "only registered players can create, join or spectate rooms")
+
+	fs.IntVar(&cfg.MaxSpectators, "max-spectators", MAX_SPECTATORS_COUNT, "maximal spectators count per room")
+	fs.DurationVar(&cfg.SpectatorRevealDelay, "spectator-reveal-delay", SPECTATOR_REVEAL_DELAY, "delay of events sent to full reveal spectators, 0 = full reveal is disabled")
+
+	fs.DurationVar(&cfg.ReaperInterval, "reaper-interval", ROOM_REAPER_INTERVAL, "how often timed out rooms are looked for")
+	fs.DurationVar(&cfg.MatchmakingInterval, "matchmaking-interval", MATCHMAKING_INTERVAL, "how often players looking for a match are paired")
+
+	return fs
+}
+
+func loadConfig(args []string) (*Config, error) {
+	cfg := &Config{}
+	fs := cfg.flagSet()
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Flags have the highest priority, so remember which ones were set explicitly
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	path := fs.Lookup("config").Value.String()
+	if !explicit["config"] {
+		path = os.Getenv(configEnvName("config"))
+	}
+
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, value := range values {
+			if fs.Lookup(name) == nil || name == "config" {
+				return nil, fmt.Errorf("%s: unknown option %q", path, name)
+			}
+			if explicit[name] {
+				continue
+			}
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s: invalid value for %q: %w", path, name, err)
+			}
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || f.Name == "config" || envErr != nil {
+			return
+		}
+		if value, ok := os.LookupEnv(configEnvName(f.Name)); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				envErr = fmt.Errorf("invalid value of %s: %w", configEnvName(f.Name), err)
+			}
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	return cfg, cfg.validate()
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	raw := map[string]any{}
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	for name, value := range raw {
+		values[name] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+func configEnvName(name string) string {
+	return CONFIG_ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", cfg.Port)
+	}
+
+	for name, timeout := range map[string]time.Duration{
+		"initial-timeout":         cfg.InitialTimeout,
+		"building-timeout":        cfg.BuildingTimeout,
+		"gameplay-timeout":        cfg.GameplayTimeout,
+		"revenge-request-timeout": cfg.RevengeRequestTimeout,
+		"ping-timeout":            cfg.PingTimeout,
+		"handshake-timeout":       cfg.HandshakeTimeout,
+		"reaper-interval":         cfg.ReaperInterval,
+		"matchmaking-interval":    cfg.MatchmakingInterval,
+	} {
+		if timeout <= 0 {
+			return fmt.Errorf("%s has to be positive, got %s", name, timeout)
+		}
+	}
+
+	if cfg.MinEventsInterval < 0 || cfg.MaxEventsCount < 1 {
+		return errors.New("antiflood: min-events-interval can't be negative and max-events-count has to be positive")
+	}
+	if cfg.MaxSecurityErrorsCount < 0 {
+		return errors.New("max-security-errors-count can't be negative")
+	}
+
+	if cfg.ReconnectGracePeriod < 0 {
+		return errors.New("reconnect-grace-period can't be negative")
+	}
+
+	if cfg.MaxSpectators < 0 || cfg.SpectatorRevealDelay < 0 {
+		return errors.New("max-spectators and spectator-reveal-delay can't be negative")
+	}
+
+	if cfg.TurnTimeout < 0 || cfg.MaxMissedTurns < 0 {
+		return errors.New("shot clock: turn-timeout and max-missed-turns can't be negative")
+	}
+	if cfg.TurnTimeoutAction != TURN_TIMEOUT_ACTION_SHOT && cfg.TurnTimeoutAction != TURN_TIMEOUT_ACTION_PASS {
+		return fmt.Errorf("shot clock: unknown turn-timeout-action %q", cfg.TurnTimeoutAction)
+	}
+
+	if cfg.BotMoveDelay < 0 {
+		return errors.New("bot-move-delay can't be negative")
+	}
+
+	if cfg.MinNicknameLen < 1 || cfg.MinNicknameLen > cfg.MaxNicknameLen {
+		return fmt.Errorf("invalid nickname length limits: %d..%d", cfg.MinNicknameLen, cfg.MaxNicknameLen)
+	}
+	regex, err := regexp.Compile(cfg.NicknameValidationRegex)
+	if err != nil {
+		return fmt.Errorf("invalid nickname-validation-regex: %w", err)
+	}
+	cfg.nicknameRegex = regex
+
+	return nil
+}
+
+func (cfg *Config) address() string {
+	return fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("Could not load default config: %s", err)
+	}
+
+	if cfg.Port != CONN_PORT || cfg.GameplayTimeout != MAX_GAMEPLAY_TIMEOUT || cfg.MaxEventsCount != MAX_EVENTS_COUNT {
+		t.Errorf("Default config has unexpected values: %+v", cfg)
+	}
+
+	if !cfg.isValidNickname("Northn") || cfg.isValidNickname("a") {
+		t.Errorf("Default nickname validation does not work")
+	}
+}
+
+func TestConfigPriority(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"port": 7000, "ping-timeout": "1m", "max-events-count": 10}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SEABATTLE_PING_TIMEOUT", "2m")
+	t.Setenv("SEABATTLE_MAX_EVENTS_COUNT", "20")
+
+	cfg, err := loadConfig([]string{"-config", path, "-max-events-count", "30"})
+	if err != nil {
+		t.Fatalf("Could not load config: %s", err)
+	}
+
+	if cfg.Port != 7000 {
+		t.Errorf("Config file value was not applied: %d", cfg.Port)
+	}
+	if cfg.PingTimeout != 2*time.Minute {
+		t.Errorf("Environment variable does not override config file: %s", cfg.PingTimeout)
+	}
+	if cfg.MaxEventsCount != 30 {
+		t.Errorf("Flag does not override environment variable: %d", cfg.MaxEventsCount)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	if _, err := loadConfig([]string{"-port", "0"}); err == nil {
+		t.Errorf("Invalid port was accepted")
+	}
+
+	if _, err := loadConfig([]string{"-min-nickname-len", "30"}); err == nil {
+		t.Errorf("Invalid nickname length limits were accepted")
+	}
+
+	t.Setenv("SEABATTLE_NICKNAME_VALIDATION_REGEX", "[")
+	if _, err := loadConfig(nil); err == nil {
+		t.Errorf("Invalid nickname regex was accepted")
+	}
+}
@@ -9,6 +9,7 @@ const (
 )
 
 // TCP server configuration
+// CONN_HOST and CONN_PORT are defaults and can be overridden at runtime, see config.go
 const (
 	CONN_HOST = "0.0.0.0"
 	CONN_PORT = 5691
@@ -21,14 +22,29 @@ const (
 	// System types
 	X_MARK     EntityType = 1 // Should be sent only to spawn X mark on opponent battlefield to hide ship position, type before destroying
 	EMPTY_CELL EntityType = 2 // DNM
+	// Sent only to show cell of shooter ship revealed by mine, see MINE_REVEAL_CELL
+	REVEALED_CELL EntityType = 9
+	// Sent only to shooter at the center of WEAPON_SONAR area
+	SONAR_CONTACT EntityType = 10 // There are ship cells which are not hit yet in the area
+	SONAR_CLEAR   EntityType = 11
 
 	FOURDECK   EntityType = 3
 	THREEDECK  EntityType = 4
 	DOUBLEDECK EntityType = 5
 	SINGLEDECK EntityType = 6
+
+	// Special types, placed like ships if room rules allow them. Both take a single cell
+	MINE EntityType = 7 // Hidden until shot at, see MineEffect
+	ROCK EntityType = 8 // Revealed to enemy when game starts and can't be shot at
+)
+
+// Default battlefield size, used if room was created without custom rules
+const (
+	DEFAULT_BATTLEFIELD_WIDTH  = 10
+	DEFAULT_BATTLEFIELD_HEIGHT = 10
 )
 
-// Ships dimensions (in horizontal)
+// Default ships dimensions (in horizontal)
 // Note that all these constant values must equal to client-side values!
 var ENTITY_SIZE = map[EntityType]Vec2{
 	FOURDECK:   {x: 4, y: 1},
@@ -37,7 +53,7 @@ var ENTITY_SIZE = map[EntityType]Vec2{
 	SINGLEDECK: {x: 1, y: 1},
 }
 
-// Ships count limits. Will be used also to check if entity is placeable
+// Default ships count limits. Will be used also to check if entity is placeable
 // Note that all these constant values must equal to client-side values!
 var ENTITY_COUNT = map[EntityType]int{
 	FOURDECK:   1,
@@ -46,6 +62,8 @@ var ENTITY_COUNT = map[EntityType]int{
 	SINGLEDECK: 4,
 }
 
+// Defaults of all values below can be overridden at runtime, see config.go
+
 const (
 	MAX_INITIAL_TIMEOUT         = 10 * time.Minute
 	MAX_BUILDING_TIMEOUT        = 10 * time.Minute
@@ -53,6 +71,98 @@ const (
 	MAX_REVENGE_REQUEST_TIMEOUT = 3 * time.Minute
 )
 
+// Shot clock
+const (
+	MAX_TURN_TIMEOUT    = 0 * time.Second // 0 = disabled
+	TURN_TIMEOUT_ACTION = TURN_TIMEOUT_ACTION_SHOT
+	MAX_MISSED_TURNS    = 3 // Forfeit after this many turns in a row were missed, 0 = never
+)
+
+// What happens when turn deadline is exceeded
+const (
+	TURN_TIMEOUT_ACTION_SHOT = "shot" // Server shoots at random cell on behalf of player
+	TURN_TIMEOUT_ACTION_PASS = "pass" // Turn passes to opponent
+)
+
+// How long room keeps slot of player who has unexpectedly lost connection, 0 = disabled
+const RECONNECT_GRACE_PERIOD = 1 * time.Minute
+
+// Directory finished games replays are saved to, empty = replays are disabled
+const REPLAY_DIR = "replays"
+
+// Players statistics
+const (
+	STATS_FILE           = "data/stats.json" // Empty = statistics are kept in memory only
+	MAX_LEADERBOARD_SIZE = 50
+	INITIAL_RATING       = 1500
+	ELO_K_FACTOR         = 32 // Maximal rating change per game
+)
+
+// Accounts
+const (
+	ACCOUNTS_FILE = "data/accounts.json" // Empty = accounts are kept in memory only
+	REQUIRE_AUTH  = false                // Only registered players can play if set
+)
+
+// Password and token requirements
+const (
+	MIN_PASSWORD_LEN         = 6
+	MAX_PASSWORD_LEN         = 72
+	PASSWORD_SALT_SIZE       = 16 // Bytes
+	PASSWORD_HASH_ITERATIONS = 100000
+	AUTH_TOKEN_SIZE          = 32 // Bytes
+	MAX_AUTH_TOKENS_COUNT    = 5  // Per account, the oldest tokens are revoked
+)
+
+// Remote address is blocked for the rest of window after this many wrong room passwords within window
+const (
+	MAX_ROOM_PASSWORD_ATTEMPTS    = 5
+	ROOM_PASSWORD_ATTEMPTS_WINDOW = 1 * time.Minute
+)
+
+// Remote address is blocked for the rest of window after this many failed logins within window
+const (
+	MAX_LOGIN_ATTEMPTS    = 10
+	LOGIN_ATTEMPTS_WINDOW = 5 * time.Minute
+)
+
+// Remote address is blocked for the rest of window after this many registrations within window
+const (
+	MAX_REGISTRATIONS    = 5
+	REGISTRATIONS_WINDOW = 1 * time.Hour
+)
+
+// Maximal count of rooms sent in a single LIST_ROOMS response
+const MAX_ROOMS_PAGE_SIZE = 20
+
+// Spectators
+const (
+	MAX_SPECTATORS_COUNT     = 20
+	SPECTATOR_REVEAL_DELAY   = 30 * time.Second // Events are sent to full reveal spectators with this delay, 0 = full reveal is disabled
+	MAX_DELAYED_EVENTS_COUNT = 1024             // Per spectator
+)
+
+// Bots
+const (
+	BOT_MOVE_DELAY             = 1 * time.Second // Bot "thinks" before each shot
+	BOT_HIT_WEIGHT             = 20              // How much ship placements covering hit cells are preferred by probability bot
+	MAX_RANDOM_FLEET_ATTEMPTS  = 100
+	MAX_RANDOM_ENTITY_ATTEMPTS = 200
+	MAX_RANDOM_TOTAL_ATTEMPTS  = 20000 // Entity attempts of the whole random placement, so big fleets don't take forever
+	TOURNAMENT_GAMES           = 100   // Default games count played by every pair of strategies in tournament mode
+)
+
+// How often the reaper looks for timed out rooms
+const ROOM_REAPER_INTERVAL = 1 * time.Second
+
+// Matchmaking
+const (
+	MATCHMAKING_INTERVAL     = 1 * time.Second // How often waiting players are paired
+	MATCHMAKING_WAIT_SAMPLES = 10              // Estimated wait is averaged over about this many last matched players
+	MATCHMAKING_RATING_GAP   = 100             // Maximal rating difference of matched players, widened over time while queued
+	MATCHMAKING_GAP_GROWTH   = 10              // Rating points per second of waiting
+)
+
 // Handshake and ping timeout
 const (
 	MAX_PING_TIMEOUT      = 600 * time.Second
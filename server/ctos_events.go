@@ -1,14 +1,70 @@
 package main
 
+type CtosShipRule struct {
+	Type_ EntityType `json:"type"`
+	Size  struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"size"` // In horizontal
+	Cells []struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"cells"` // Optional shape in horizontal, size is ignored if set. Rotations are clockwise, see DirectionType
+	Count int `json:"count"`
+}
+
+type CtosRules struct {
+	Width  int            `json:"width"`
+	Height int            `json:"height"`
+	Fleet  []CtosShipRule `json:"fleet"`
+	Salvo  bool           `json:"salvo"` // Optional, classic one shot per turn if omitted
+
+	AllowTouching bool `json:"allowTouching"` // Optional, ships can't touch each other if omitted
+
+	Mines      int        `json:"mines"`      // Optional, count of MINE entities placed with ships
+	Rocks      int        `json:"rocks"`      // Optional, count of ROCK entities placed with ships
+	MineEffect MineEffect `json:"mineEffect"` // Optional, MINE_SKIP_TURN if omitted
+
+	Weapons map[WeaponType]int `json:"weapons"` // Optional, uses of every weapon each player has per game
+
+	Teams        bool `json:"teams"`        // Optional, room for two teams of two players
+	SharedBoards bool `json:"sharedBoards"` // Optional, teammates defend a single battlefield
+}
+
 type CtosCreateRoom struct {
-	Nickname string `json:"nickname"`
-	Version  string `json:"version"`
+	Nickname string     `json:"nickname"`
+	Version  string     `json:"version"`
+	Rules    *CtosRules `json:"rules"`    // Optional, default rules are used if omitted
+	Public   bool       `json:"public"`   // Room is listed in LIST_ROOMS, otherwise it can be joined only by uid
+	Password string     `json:"password"` // Optional, required to join or spectate the room if set
+}
+
+type CtosCreateAiRoom struct {
+	Nickname   string        `json:"nickname"`
+	Version    string        `json:"version"`
+	Rules      *CtosRules    `json:"rules"`      // Optional, default rules are used if omitted
+	Difficulty BotDifficulty `json:"difficulty"` // "random", "hunt" or "probability"
 }
 
 type CtosJoinRoom struct {
 	Nickname string `json:"nickname"`
 	RoomUid  string `json:"roomUid"`
 	Version  string `json:"version"`
+	Password string `json:"password"` // Required if room is protected with password
+}
+
+type CtosSpectateRoom struct {
+	Nickname   string `json:"nickname"`
+	RoomUid    string `json:"roomUid"`
+	Version    string `json:"version"`
+	FullReveal bool   `json:"fullReveal"` // See every ship with a delay
+	Password   string `json:"password"`   // Required if room is protected with password
+}
+
+type CtosResumeSession struct {
+	RoomUid      string `json:"roomUid"`
+	SessionToken string `json:"sessionToken"`
+	Version      string `json:"version"`
 }
 
 type CtosReadyToPlay struct {
@@ -22,7 +78,80 @@ type CtosReadyToPlay struct {
 	} `json:"entities"`
 }
 
+type CtosPlaceEntity struct {
+	Type_    EntityType `json:"type"`
+	Position struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"position"`
+	Direction DirectionType `json:"direction"`
+}
+
+type CtosRemoveEntity struct {
+	Position struct { // Any point of the ship
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"position"`
+}
+
+type CtosMoveEntity struct {
+	From struct { // Any point of the ship
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"from"`
+	Position struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"position"`
+	Direction DirectionType `json:"direction"`
+}
+
+type CtosSalvo struct {
+	Shots []struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"shots"`
+}
+
+type CtosUseWeapon struct {
+	Weapon    WeaponType     `json:"weapon"`
+	X         int            `json:"x"`
+	Y         int            `json:"y"`
+	Direction DirectionType  `json:"direction"` // Torpedo only: HORIZONTAL runs along the row, VERTICAL along the column
+	Target    PlayerRoleType `json:"target"`    // Team rooms only: opponent to shoot at, the last chosen one if omitted
+}
+
 type CtosShotAt struct {
-	X int `json:"x"`
-	Y int `json:"y"`
+	X      int            `json:"x"`
+	Y      int            `json:"y"`
+	Target PlayerRoleType `json:"target"` // Team rooms only: opponent to shoot at, the last chosen one if omitted
+}
+
+type CtosPlayerStats struct {
+	Nickname string `json:"nickname"`
+}
+
+type CtosLeaderboard struct {
+	Limit int `json:"limit"` // 1..MAX_LEADERBOARD_SIZE
+}
+
+type CtosRegister struct {
+	Nickname string `json:"nickname"`
+	Password string `json:"password"`
+}
+
+type CtosLogin struct {
+	Nickname string `json:"nickname"`
+	Password string `json:"password"` // Either password or token
+	Token    string `json:"token"`
+}
+
+type CtosFindMatch struct {
+	Nickname string `json:"nickname"`
+	Version  string `json:"version"`
+}
+
+type CtosListRooms struct {
+	Page     int `json:"page"`     // Starting from 0
+	PageSize int `json:"pageSize"` // 1..MAX_ROOMS_PAGE_SIZE
 }
@@ -3,53 +3,71 @@ package main
 import (
 	"errors"
 	"fmt"
+	"slices"
 )
 
 type EntityType int
 type DirectionType int
 
+// Directions are clockwise rotations of the ship shape defined in horizontal
 const (
 	HORIZONTAL DirectionType = 1
 	VERTICAL   DirectionType = 2
+	ROTATED180 DirectionType = 3 // Allowed only for shapes which differ from themselves rotated by 180°, see Rules.directions
+	ROTATED270 DirectionType = 4
 )
 
 type Entity struct {
 	type_           EntityType
 	position        Vec2
 	direction       DirectionType
+	baseSize        Vec2   // Dimensions in horizontal, taken from room rules
+	baseCells       []Vec2 // Shape cells in horizontal, nil if ship is a rectangle of baseSize
 	destroyedPoints []Vec2
 }
 
-func newEntity(type_ EntityType, position Vec2, direction DirectionType) (Entity, error) {
+func newEntity(rules *Rules, type_ EntityType, position Vec2, direction DirectionType) (Entity, error) {
 	entity := Entity{
 		type_:     type_,
 		position:  position,
 		direction: direction,
 	}
 
-	if direction != HORIZONTAL && direction != VERTICAL {
+	ship, ok := rules.entityRule(type_)
+	if !ok {
+		return entity, fmt.Errorf("unknown entity type: %d", type_)
+	}
+	entity.baseSize = ship.Size
+	entity.baseCells = ship.Cells
+
+	if !slices.Contains(rules.directions(type_), direction) {
 		return entity, fmt.Errorf("incorrect entity orientation: %d", direction)
 	}
 
 	dimensions := entity.dimensions()
-	if !(dimensions.start.x >= 1 && dimensions.start.y >= 1 && dimensions.end.x <= 10 && dimensions.end.y <= 10) {
+	if !(rules.inBounds(dimensions.start) && rules.inBounds(dimensions.end)) {
 		return entity, fmt.Errorf("incorrect entity boundaries: type: %d, dimensions: %+v, orientation: %d", type_, dimensions, direction)
 	}
 
 	return entity, nil
 }
 
+// ship reports if entity is a ship, not a special entity like mine or rock
+func (ent *Entity) ship() bool {
+	return ent.type_ != MINE && ent.type_ != ROCK
+}
+
 func (ent *Entity) size() Vec2 {
-	size_ := ENTITY_SIZE[ent.type_]
-	x := size_.x
-	y := size_.y
+	x := ent.baseSize.x
+	y := ent.baseSize.y
 
-	if ent.direction != HORIZONTAL {
+	if ent.direction == VERTICAL || ent.direction == ROTATED270 {
 		x, y = y, x
 	}
 	return Vec2{x: x, y: y}
 }
 
+// dimensions returns bounding box of the entity
 func (ent *Entity) dimensions() Vec2x2 {
 	size_ := ent.size()
 
@@ -59,24 +77,118 @@ func (ent *Entity) dimensions() Vec2x2 {
 	}
 }
 
-func (ent *Entity) intersects(anotherEntity Entity) bool {
-	dimensions := ent.dimensions()
-	dimensions.start.sub(1)
-	dimensions.end.add(1)
-	return dimensions.intersects(anotherEntity.dimensions())
+// cells returns local points (starting from 1 at dimensions start) occupied by entity in its direction
+func (ent *Entity) cells() []Vec2 {
+	base := ent.baseCells
+	if base == nil {
+		base = rectangleCells(ent.baseSize)
+	}
+
+	width, height := ent.baseSize.x, ent.baseSize.y
+	cells := make([]Vec2, 0, len(base))
+	for _, cell := range base {
+		switch ent.direction {
+		case VERTICAL:
+			cell = Vec2{x: height - cell.y + 1, y: cell.x}
+		case ROTATED180:
+			cell = Vec2{x: width - cell.x + 1, y: height - cell.y + 1}
+		case ROTATED270:
+			cell = Vec2{x: cell.y, y: width - cell.x + 1}
+		}
+		cells = append(cells, cell)
+	}
+	return cells
 }
 
-func (ent *Entity) convertAbsPointToLocal(point Vec2) (Vec2, error) {
-	size_ := ent.size()
-	dimensions := ent.dimensions()
+func rectangleCells(size_ Vec2) []Vec2 {
+	cells := make([]Vec2, 0, size_.x*size_.y)
+	for x := 1; x <= size_.x; x++ {
+		for y := 1; y <= size_.y; y++ {
+			cells = append(cells, Vec2{x: x, y: y})
+		}
+	}
+	return cells
+}
+
+// absPoints returns all battlefield points occupied by entity
+func (ent *Entity) absPoints() []Vec2 {
+	start := ent.dimensions().start
+	cells := ent.cells()
+	points := make([]Vec2, 0, len(cells))
+	for _, cell := range cells {
+		points = append(points, Vec2{x: start.x + cell.x - 1, y: start.y + cell.y - 1})
+	}
+	return points
+}
+
+// haloPoints returns points around entity which are not occupied by it, they may be out of battlefield
+func (ent *Entity) haloPoints() []Vec2 {
+	points := ent.absPoints()
+	var halo []Vec2
+	for _, point := range points {
+		for x := point.x - 1; x <= point.x+1; x++ {
+			for y := point.y - 1; y <= point.y+1; y++ {
+				if around := (Vec2{x: x, y: y}); !slices.Contains(points, around) && !slices.Contains(halo, around) {
+					halo = append(halo, around)
+				}
+			}
+		}
+	}
+	return halo
+}
+
+// areas returns rectangles covering exactly the entity, so they can be cleared without touching neighbour cells
+func (ent *Entity) areas() []Vec2x2 {
+	if ent.baseCells == nil {
+		return []Vec2x2{ent.dimensions()}
+	}
+	var areas []Vec2x2
+	for _, point := range ent.absPoints() {
+		areas = append(areas, Vec2x2{start: point, end: point})
+	}
+	return areas
+}
+
+// intersects reports if entities overlap or touch each other
+func (ent *Entity) intersects(anotherEntity Entity) bool {
+	if ent.baseCells == nil && anotherEntity.baseCells == nil {
+		dimensions := ent.dimensions()
+		dimensions.start.sub(1)
+		dimensions.end.add(1)
+		return dimensions.intersects(anotherEntity.dimensions())
+	}
 
-	for x := 0; x < size_.x; x++ {
-		for y := 0; y < size_.y; y++ {
-			if dimensions.start.x+x == point.x && dimensions.start.y+y == point.y {
-				return Vec2{x: x + 1, y: y + 1}, nil
+	for _, point := range ent.absPoints() {
+		for _, anotherPoint := range anotherEntity.absPoints() {
+			if abs(point.x-anotherPoint.x) <= 1 && abs(point.y-anotherPoint.y) <= 1 {
+				return true
 			}
 		}
 	}
+	return false
+}
+
+// overlaps is like intersects, but entities are allowed to touch each other
+func (ent *Entity) overlaps(anotherEntity Entity) bool {
+	if ent.baseCells == nil && anotherEntity.baseCells == nil {
+		return ent.dimensions().intersects(anotherEntity.dimensions())
+	}
+
+	points := ent.absPoints()
+	for _, anotherPoint := range anotherEntity.absPoints() {
+		if slices.Contains(points, anotherPoint) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ent *Entity) convertAbsPointToLocal(point Vec2) (Vec2, error) {
+	start := ent.dimensions().start
+	local := Vec2{x: point.x - start.x + 1, y: point.y - start.y + 1}
+	if slices.Contains(ent.cells(), local) {
+		return local, nil
+	}
 
 	return Vec2{x: -1, y: -1}, errors.New("point is out of entity boundaries")
 }
@@ -103,7 +215,26 @@ func (ent *Entity) destroyAtAbs(point Vec2) bool {
 	return ent.destroyAt(localPoint)
 }
 
+func (ent *Entity) destroyedAbsPoints() []Vec2 {
+	start := ent.dimensions().start
+	points := make([]Vec2, 0, len(ent.destroyedPoints))
+	for _, point := range ent.destroyedPoints {
+		points = append(points, Vec2{x: start.x + point.x - 1, y: start.y + point.y - 1})
+	}
+	return points
+}
+
 func (ent *Entity) destroyed() bool {
+	if ent.baseCells != nil {
+		return len(ent.destroyedPoints) == len(ent.baseCells)
+	}
 	size_ := ent.size()
 	return len(ent.destroyedPoints) == size_.x*size_.y
 }
+
+func abs(num int) int {
+	if num < 0 {
+		return -num
+	}
+	return num
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"math"
+	"slices"
 	"testing"
 )
 
 func TestSummonBoundaries(t *testing.T) {
-	_, err1 := newEntity(FOURDECK, Vec2{x: 1, y: 3}, VERTICAL)
-	_, err2 := newEntity(FOURDECK, Vec2{x: 8, y: 1}, HORIZONTAL)
-	_, err3 := newEntity(FOURDECK, Vec2{x: 6, y: 4}, VERTICAL)
-	_, err4 := newEntity(FOURDECK, Vec2{x: 6, y: 4}, DirectionType(3))
+	rules := defaultRules()
+
+	_, err1 := newEntity(&rules, FOURDECK, Vec2{x: 1, y: 3}, VERTICAL)
+	_, err2 := newEntity(&rules, FOURDECK, Vec2{x: 8, y: 1}, HORIZONTAL)
+	_, err3 := newEntity(&rules, FOURDECK, Vec2{x: 6, y: 4}, VERTICAL)
+	_, err4 := newEntity(&rules, FOURDECK, Vec2{x: 6, y: 4}, DirectionType(3))
 
 	if err1 == nil || err2 == nil {
 		t.Errorf("Entities are out of boundaries but were summoned: %s %s", err1, err2)
@@ -24,9 +28,11 @@ func TestSummonBoundaries(t *testing.T) {
 }
 
 func TestDimensions(t *testing.T) {
+	rules := defaultRules()
+
 	startPos := Vec2{x: 5, y: 5}
 
-	entity, _ := newEntity(FOURDECK, startPos, VERTICAL)
+	entity, _ := newEntity(&rules, FOURDECK, startPos, VERTICAL)
 	dimensions := entity.dimensions()
 
 	if !(dimensions.start.x == 5 && dimensions.start.y == 2 && dimensions.end.x == 5 && dimensions.end.y == 5) {
@@ -35,9 +41,11 @@ func TestDimensions(t *testing.T) {
 }
 
 func TestIntersection(t *testing.T) {
+	rules := defaultRules()
+
 	{
-		entity1, _ := newEntity(DOUBLEDECK, Vec2{x: 2, y: 2}, HORIZONTAL)
-		entity2, _ := newEntity(FOURDECK, Vec2{x: 5, y: 5}, VERTICAL)
+		entity1, _ := newEntity(&rules, DOUBLEDECK, Vec2{x: 2, y: 2}, HORIZONTAL)
+		entity2, _ := newEntity(&rules, FOURDECK, Vec2{x: 5, y: 5}, VERTICAL)
 
 		if entity1.intersects(entity2) {
 			t.Errorf("Entities intersect but should not")
@@ -45,8 +53,8 @@ func TestIntersection(t *testing.T) {
 	}
 
 	{
-		entity1, _ := newEntity(DOUBLEDECK, Vec2{x: 2, y: 5}, HORIZONTAL)
-		entity2, _ := newEntity(FOURDECK, Vec2{x: 4, y: 4}, VERTICAL)
+		entity1, _ := newEntity(&rules, DOUBLEDECK, Vec2{x: 2, y: 5}, HORIZONTAL)
+		entity2, _ := newEntity(&rules, FOURDECK, Vec2{x: 4, y: 4}, VERTICAL)
 
 		if !entity1.intersects(entity2) {
 			t.Errorf("Entities do not intersect but have to")
@@ -54,8 +62,8 @@ func TestIntersection(t *testing.T) {
 	}
 
 	{
-		entity1, _ := newEntity(DOUBLEDECK, Vec2{x: 2, y: 5}, HORIZONTAL)
-		entity2, _ := newEntity(FOURDECK, Vec2{x: 6, y: 4}, VERTICAL)
+		entity1, _ := newEntity(&rules, DOUBLEDECK, Vec2{x: 2, y: 5}, HORIZONTAL)
+		entity2, _ := newEntity(&rules, FOURDECK, Vec2{x: 6, y: 4}, VERTICAL)
 
 		if entity1.intersects(entity2) {
 			t.Errorf("Entities intersect but should not")
@@ -63,17 +71,69 @@ func TestIntersection(t *testing.T) {
 	}
 
 	{
-		entity1, _ := newEntity(THREEDECK, Vec2{x: 5, y: 8}, HORIZONTAL)
-		entity2, _ := newEntity(THREEDECK, Vec2{x: 2, y: 10}, HORIZONTAL)
+		entity1, _ := newEntity(&rules, THREEDECK, Vec2{x: 5, y: 8}, HORIZONTAL)
+		entity2, _ := newEntity(&rules, THREEDECK, Vec2{x: 2, y: 10}, HORIZONTAL)
 
 		if entity1.intersects(entity2) {
 			t.Errorf("Entities intersect but should not")
 		}
 	}
+	{
+		entity1, _ := newEntity(&rules, DOUBLEDECK, Vec2{x: 2, y: 5}, HORIZONTAL)
+		entity2, _ := newEntity(&rules, FOURDECK, Vec2{x: 4, y: 5}, VERTICAL)
+
+		if !entity1.intersects(entity2) || entity1.overlaps(entity2) {
+			t.Errorf("Touching entities have to intersect, but not overlap")
+		}
+	}
+}
+
+func TestTouchingShips(t *testing.T) {
+	// Battlefield can be filled with touching ships only
+	rules := Rules{Width: 5, Height: 5, AllowTouching: true, Fleet: map[EntityType]ShipRule{
+		FOURDECK: {Size: Vec2{x: 5, y: 1}, Count: 5},
+	}}
+	room := newTestRoom(t, newTestConfig(t), rules)
+	defer room.destroy()
+
+	for _, player := range room.players() {
+		for y := 1; y <= 5; y++ {
+			player.placeEntity(FOURDECK, Vec2{x: 1, y: y}, HORIZONTAL)
+		}
+		player.placeEntity(FOURDECK, Vec2{x: 1, y: 5}, HORIZONTAL) // Overlaps
+		if !player.built() || len(player.entities) != 5 {
+			t.Fatalf("Touching ships were not placed: %d entities", len(player.entities))
+		}
+		player.announceReady()
+	}
+	if !room.startPlaying() {
+		t.Fatalf("Could not start playing")
+	}
+
+	for x := 1; x <= 5; x++ {
+		room.fire(room.primary, Vec2{x: x, y: 3})
+	}
+	if len(room.secondary.shotPoints) != 5 {
+		t.Errorf("Cells around sunk ship were revealed: %+v", room.secondary.shotPoints)
+	}
+}
+
+func TestTouchingBots(t *testing.T) {
+	rules := defaultRules()
+	rules.AllowTouching = true
+	for _, difficulty := range []BotDifficulty{BOT_HUNT_TARGET, BOT_PROBABILITY} {
+		for i := 0; i < 10; i++ {
+			if _, err := playOfflineGame(newTestConfig(t), rules, difficulty, BOT_RANDOM); err != nil {
+				t.Fatalf("Could not play game with touching ships: %s", err)
+			}
+		}
+	}
 }
 
 func TestHorizontalAbsToLocalPoint(t *testing.T) {
-	entity, _ := newEntity(THREEDECK, Vec2{x: 3, y: 4}, HORIZONTAL)
+	rules := defaultRules()
+
+	entity, _ := newEntity(&rules, THREEDECK, Vec2{x: 3, y: 4}, HORIZONTAL)
 
 	for i := 0; i <= 2; i++ {
 		point, err := entity.convertAbsPointToLocal(Vec2{x: 3 + i, y: 4})
@@ -95,7 +155,9 @@ func TestHorizontalAbsToLocalPoint(t *testing.T) {
 }
 
 func TestVerticalAbsToLocalPoint(t *testing.T) {
-	entity, _ := newEntity(THREEDECK, Vec2{x: 3, y: 4}, VERTICAL)
+	rules := defaultRules()
+
+	entity, _ := newEntity(&rules, THREEDECK, Vec2{x: 3, y: 4}, VERTICAL)
 
 	for i := 0; i <= 2; i++ {
 		point, err := entity.convertAbsPointToLocal(Vec2{x: 3, y: 2 + i})
@@ -117,7 +179,9 @@ func TestVerticalAbsToLocalPoint(t *testing.T) {
 }
 
 func TestDestroy(t *testing.T) {
-	entity, _ := newEntity(THREEDECK, Vec2{x: 3, y: 4}, VERTICAL)
+	rules := defaultRules()
+
+	entity, _ := newEntity(&rules, THREEDECK, Vec2{x: 3, y: 4}, VERTICAL)
 
 	if entity.destroyAtAbs(Vec2{x: 5, y: 5}) {
 		t.Errorf("Entity point was destroyed but should not")
@@ -142,3 +206,105 @@ func TestDestroy(t *testing.T) {
 		t.Errorf("Entity is not destroyed but has to be")
 	}
 }
+
+func newShapeRules(t *testing.T) Rules {
+	requested := CtosRules{Width: 10, Height: 10}
+	for type_, cells := range map[EntityType][][2]int{
+		FOURDECK:   {{0, 0}, {0, 1}, {0, 2}, {1, 0}}, // L
+		THREEDECK:  {{0, 0}, {1, 0}, {2, 0}, {1, 1}}, // T
+		DOUBLEDECK: {{0, 0}, {0, 1}, {1, 0}, {1, 1}}, // Square
+	} {
+		ship := CtosShipRule{Type_: type_, Count: 1}
+		for _, cell := range cells {
+			ship.Cells = append(ship.Cells, struct {
+				X int `json:"x"`
+				Y int `json:"y"`
+			}{X: cell[0], Y: cell[1]})
+		}
+		requested.Fleet = append(requested.Fleet, ship)
+	}
+
+	rules, err := newRules(&requested)
+	if err != nil {
+		t.Fatalf("Shape rules are valid but were rejected: %s", err)
+	}
+	return rules
+}
+
+func TestShapes(t *testing.T) {
+	rules := newShapeRules(t)
+	if rules.Fleet[DOUBLEDECK].Cells != nil || rules.Fleet[DOUBLEDECK].Size != (Vec2{x: 2, y: 2}) {
+		t.Errorf("Square was not stored as rectangle: %+v", rules.Fleet[DOUBLEDECK])
+	}
+	if _, err := newEntity(&rules, DOUBLEDECK, Vec2{x: 5, y: 5}, ROTATED180); err == nil {
+		t.Errorf("Symmetric shape was rotated by 180°")
+	}
+
+	// L: cells 1:1, 1:2, 1:3, 2:1 in horizontal
+	expected := map[DirectionType][]Vec2{
+		HORIZONTAL: {{x: 5, y: 3}, {x: 5, y: 4}, {x: 5, y: 5}, {x: 6, y: 3}},
+		VERTICAL:   {{x: 7, y: 4}, {x: 6, y: 4}, {x: 5, y: 4}, {x: 7, y: 5}},
+		ROTATED180: {{x: 6, y: 5}, {x: 6, y: 4}, {x: 6, y: 3}, {x: 5, y: 5}},
+		ROTATED270: {{x: 5, y: 5}, {x: 6, y: 5}, {x: 7, y: 5}, {x: 5, y: 4}},
+	}
+	for direction, points := range expected {
+		entity, err := newEntity(&rules, FOURDECK, Vec2{x: 5, y: 5}, direction)
+		if err != nil {
+			t.Fatalf("Could not summon L in direction %d: %s", direction, err)
+		}
+		if absPoints := entity.absPoints(); !slices.Equal(absPoints, points) {
+			t.Errorf("Unexpected L points in direction %d: %+v", direction, absPoints)
+		}
+		for _, point := range points {
+			if !entity.destroyAtAbs(point) {
+				t.Errorf("Could not destroy L at %+v in direction %d", point, direction)
+			}
+		}
+		if !entity.destroyed() {
+			t.Errorf("L is not destroyed in direction %d", direction)
+		}
+	}
+
+	l, _ := newEntity(&rules, FOURDECK, Vec2{x: 1, y: 3}, HORIZONTAL)        // 1:1..1:3, 2:1
+	square, _ := newEntity(&rules, DOUBLEDECK, Vec2{x: 3, y: 4}, HORIZONTAL) // 3:3..4:4
+	if _, err := l.convertAbsPointToLocal(Vec2{x: 2, y: 3}); err == nil {
+		t.Errorf("Empty cell inside bounding box belongs to L")
+	}
+	if l.intersects(square) {
+		t.Errorf("Square placed inside L corner touches it")
+	}
+	square, _ = newEntity(&rules, DOUBLEDECK, Vec2{x: 2, y: 4}, HORIZONTAL) // 2:3..3:4
+	if !l.intersects(square) || l.overlaps(square) {
+		t.Errorf("Square touching L has to intersect, but not overlap it")
+	}
+}
+
+func TestInvalidShapes(t *testing.T) {
+	for _, cells := range [][]Vec2{
+		{{x: 0, y: 0}, {x: 2, y: 0}},
+		{{x: 0, y: 0}, {x: 0, y: 0}, {x: 1, y: 1}, {x: 1, y: 0}},
+	} {
+		ship, err := newShipRule(cells, 1)
+		if err != nil {
+			t.Fatalf("Could not build shape %+v: %s", cells, err)
+		}
+		rules := Rules{Width: 10, Height: 10, Fleet: map[EntityType]ShipRule{FOURDECK: ship}}
+		if rules.validate() == nil {
+			t.Errorf("Invalid shape was accepted: %+v", ship.Cells)
+		}
+	}
+
+	huge := make([]Vec2, MAX_BATTLEFIELD_SIZE*MAX_BATTLEFIELD_SIZE+1)
+	for i := range huge {
+		huge[i] = Vec2{x: i % MAX_BATTLEFIELD_SIZE, y: i / MAX_BATTLEFIELD_SIZE}
+	}
+	for _, cells := range [][]Vec2{
+		huge,
+		{{x: 0, y: 0}, {x: MAX_BATTLEFIELD_SIZE, y: 0}},
+		{{x: math.MinInt, y: 0}, {x: math.MaxInt, y: 0}},
+	} {
+		if _, err := newShipRule(cells, 1); err == nil {
+			t.Errorf("Too large shape of %d cells was accepted", len(cells))
+		}
+	}
+}
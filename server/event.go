@@ -26,11 +26,46 @@ const ( // CTOS: Client to Server, STOC: Server to Client
 	GAMEPLAY_TIMEOUT_EXCEEDED EventCode = 12 // STOC: data: nil // Sent if isInitialTimeoutExceeded() || isGameplayTimeoutExceeded() || isBuildingTimeoutExceeded()
 	PLAYER_DISCONNECTED       EventCode = 13 // STOC: see StocPlayerDisconnected
 	SET_GAMESTATE             EventCode = 14 // STOC: see StocSetGamestate
-	READY_TO_PLAY             EventCode = 15 // CTOS: see CtosReadyToPlay; STOC: see StosReadyToPlay
+	READY_TO_PLAY             EventCode = 15 // CTOS: see CtosReadyToPlay; STOC: see StosReadyToPlay // Entities may be omitted if fleet was placed with AUTO_PLACE/PLACE_ENTITY
 	CLEAR_BATTLEFIELD         EventCode = 16 // STOC: see StocClearBattlefield // Clears all entities in area
 	ADD_ENTITY                EventCode = 17 // STOC: see StocAddEntity // Adds entity to battlefield
 	SET_TURN                  EventCode = 18 // STOC: see StocAddEntity // Sets current player turn
 	SHOT_AT                   EventCode = 19 // CTOS: see CtosShotAt // Gameplay process itself
 	PLAYER_WIN                EventCode = 20 // STOC: see StocPlayerWin // Sent when one of players are done and won
 	REVENGE_REQUESTED         EventCode = 21 // STOC: see StocRevengeRequested; CTOS: data: nil
+	INVALID_RULES             EventCode = 22 // STOC: see StocInvalidRules // Sent as response to CREATE_ROOM CTOS if requested rules are invalid
+	SET_TURN_DEADLINE         EventCode = 23 // STOC: see StocSetTurnDeadline // Sent after SET_TURN and after every successful shot if shot clock is enabled
+	TURN_TIMEOUT_EXCEEDED     EventCode = 24 // STOC: see StocTurnTimeoutExceeded // Sent if current player has not made a move before turn deadline
+	PLAYER_CONNECTION_LOST    EventCode = 25 // STOC: see StocPlayerConnectionLost // Sent if player has lost connection but his slot is kept for a grace period
+	RESUME_SESSION            EventCode = 26 // CTOS: see CtosResumeSession // Attaches new connection to the slot kept after connection loss
+	INVALID_SESSION           EventCode = 27 // STOC: data: nil // Sent as response to RESUME_SESSION CTOS if there is no slot to resume with specified token
+	PLAYER_RECONNECTED        EventCode = 28 // STOC: see StocPlayerReconnected // Sent when player has resumed his session, followed by full state replay to him
+	REQUEST_STATE             EventCode = 29 // CTOS: data: nil // Requests STATE_SNAPSHOT
+	STATE_SNAPSHOT            EventCode = 30 // STOC: see StocStateSnapshot // Full room state as it is seen by the player
+	SPECTATE_ROOM             EventCode = 31 // CTOS: see CtosSpectateRoom; STOC: see StocSpectateRoom // ROOM_IS_FULL is sent if spectators limit is reached
+	PLAYER_STATS              EventCode = 32 // CTOS: see CtosPlayerStats; STOC: see StocPlayerStats // Can be sent at any time
+	LEADERBOARD               EventCode = 33 // CTOS: see CtosLeaderboard; STOC: see StocLeaderboard // Can be sent at any time
+	REGISTER                  EventCode = 34 // CTOS: see CtosRegister; STOC: see StocAuthenticated // Creates account and logs in, sent before CREATE_ROOM/JOIN_ROOM/SPECTATE_ROOM
+	LOGIN                     EventCode = 35 // CTOS: see CtosLogin; STOC: see StocAuthenticated // Sent before CREATE_ROOM/JOIN_ROOM/SPECTATE_ROOM, nickname from them is ignored then
+	AUTH_FAILED               EventCode = 36 // STOC: data: nil // Sent as response to LOGIN CTOS if there is no such account or password/token is invalid
+	NICKNAME_TAKEN            EventCode = 37 // STOC: data: nil // Sent if nickname is registered, but player has not logged in or tries to register it again
+	AUTH_REQUIRED             EventCode = 38 // STOC: data: nil // Sent as response to CREATE_ROOM/JOIN_ROOM/SPECTATE_ROOM CTOS if server requires authentication
+	INVALID_PASSWORD          EventCode = 39 // STOC: data: nil // Sent as response to REGISTER CTOS if password is shorter than MIN_PASSWORD_LEN or longer than MAX_PASSWORD_LEN, or to CREATE_ROOM CTOS if room password is longer than MAX_PASSWORD_LEN
+	FIND_MATCH                EventCode = 40 // CTOS: see CtosFindMatch; STOC: see StocMatchQueue // Puts player to matchmaking queue, STOC is sent again when queue position changes
+	CANCEL_MATCH              EventCode = 41 // CTOS: data: nil; STOC: data: nil // Removes player from matchmaking queue
+	MATCH_FOUND               EventCode = 42 // STOC: see StocMatchFound // Sent when player was paired with another one, followed by JOIN_ROOM
+	RATING_UPDATE             EventCode = 43 // STOC: see StocRatingUpdate // Sent after PLAYER_WIN
+	LIST_ROOMS                EventCode = 44 // CTOS: see CtosListRooms; STOC: see StocListRooms // Lists public rooms, can be sent at any time
+	INVALID_ROOM_PASSWORD     EventCode = 45 // STOC: see StocInvalidRoomPassword // Sent as response to JOIN_ROOM/SPECTATE_ROOM CTOS if room password is wrong or too many wrong guesses were made
+	CREATE_AI_ROOM            EventCode = 46 // CTOS: see CtosCreateAiRoom; STOC: see StocCreateRoom // Creates room with bot as secondary player, followed by JOIN_ROOM
+	AUTO_PLACE                EventCode = 47 // CTOS: data: nil // Places the whole fleet at random in BUILDING state, server responds with CLEAR_BATTLEFIELD and ADD_ENTITY; confirm it with READY_TO_PLAY without entities
+	PLACE_ENTITY              EventCode = 48 // CTOS: see CtosPlaceEntity // Places single ship in BUILDING state, server responds with ADD_ENTITY or INVALID_ENTITY
+	REMOVE_ENTITY             EventCode = 49 // CTOS: see CtosRemoveEntity // Removes ship covering the point in BUILDING state, server responds with CLEAR_BATTLEFIELD or INVALID_ENTITY
+	MOVE_ENTITY               EventCode = 50 // CTOS: see CtosMoveEntity // Moves/rotates ship covering the point in BUILDING state, server responds with CLEAR_BATTLEFIELD and ADD_ENTITY or INVALID_ENTITY
+	INVALID_ENTITY            EventCode = 51 // STOC: see StocInvalidEntity // Sent as response to PLACE_ENTITY/REMOVE_ENTITY/MOVE_ENTITY CTOS if ship can't be placed there, fleet is left unchanged
+	CANCEL_READY              EventCode = 52 // CTOS: data: nil; STOC: see StocCancelReady // Takes READY_TO_PLAY back while enemy is still building, fleet is cleared
+	SALVO                     EventCode = 53 // CTOS: see CtosSalvo; STOC: see StocSalvo // Replaces SHOT_AT in rooms with salvo rules: all shots of the turn are resolved at once, then turn is switched
+	MINE_EXPLODED             EventCode = 54 // STOC: see StocMineExploded // Sent after shot at mine, followed by ADD_ENTITY of REVEALED_CELL if it is revealed
+	USE_WEAPON                EventCode = 55 // CTOS: see CtosUseWeapon; STOC: see StocUseWeapon // Used instead of SHOT_AT in rooms with weapons, STOC is followed by ADD_ENTITY of every shot or of SONAR_CONTACT/SONAR_CLEAR
+	TOO_MANY_ATTEMPTS         EventCode = 56 // STOC: see StocTooManyAttempts // Sent as response to LOGIN/REGISTER CTOS if too many of them were made from the remote host, request is not processed then
 )
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeClock) advance(duration time.Duration) {
+	clock.now = clock.now.Add(duration)
+}
+
+func newTestConfig(t *testing.T) *Config {
+	cfg := defaultConfig()
+	cfg.ReplayDir = t.TempDir()
+	return cfg
+}
+
+func newTestPlayer(cfg *Config, name string) *Player {
+	return &Player{
+		cfg:        cfg,
+		name:       name,
+		remoteAddr: name,
+	}
+}
+
+func placeTestFleet(t *testing.T, player *Player) {
+	fleet := []struct {
+		type_    EntityType
+		position Vec2
+	}{
+		{FOURDECK, Vec2{x: 1, y: 1}},
+		{THREEDECK, Vec2{x: 1, y: 3}},
+		{THREEDECK, Vec2{x: 5, y: 3}},
+		{DOUBLEDECK, Vec2{x: 1, y: 5}},
+		{DOUBLEDECK, Vec2{x: 4, y: 5}},
+		{DOUBLEDECK, Vec2{x: 7, y: 5}},
+		{SINGLEDECK, Vec2{x: 1, y: 7}},
+		{SINGLEDECK, Vec2{x: 3, y: 7}},
+		{SINGLEDECK, Vec2{x: 5, y: 7}},
+		{SINGLEDECK, Vec2{x: 7, y: 7}},
+	}
+
+	for _, ship := range fleet {
+		entity, err := newEntity(player.rules(), ship.type_, ship.position, HORIZONTAL)
+		if err != nil {
+			t.Fatalf("Could not summon test entity: %s", err)
+		}
+		if err := player.addEntity(entity); err != nil {
+			t.Fatalf("Could not add test entity: %s", err)
+		}
+	}
+	player.ready = true
+}
+
+// newTestRoom creates room by the rules and fills all its slots, so building stage starts
+func newTestRoom(t *testing.T, cfg *Config, rules Rules) *Room {
+	if err := rules.validate(); err != nil {
+		t.Fatalf("Test rules are invalid: %s", err)
+	}
+
+	room := createRoom(newTestPlayer(cfg, "primary"), rules)
+	for _, name := range []string{"secondary", "tertiary", "quaternary"}[:len(room.roles())-1] {
+		if !room.addPlayer(newTestPlayer(cfg, name)) {
+			t.Fatalf("Could not add %s", name)
+		}
+	}
+	return room
+}
+
+// newTestPlayingRoom creates room by the rules and starts playing with placeTestFleet on every battlefield
+func newTestPlayingRoom(t *testing.T, cfg *Config, rules Rules) *Room {
+	room := newTestRoom(t, cfg, rules)
+	for _, player := range room.players() {
+		if player.ownsBoard() {
+			placeTestFleet(t, player)
+		}
+	}
+	if !room.startPlaying() {
+		t.Fatalf("Could not start playing")
+	}
+	return room
+}
+
+func mustMarshal(t *testing.T, data any) json.RawMessage {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("Could not encode %+v: %s", data, err)
+	}
+	return raw
+}
+
+// connectTestPlayer gives player a connection and returns events received through it
+func connectTestPlayer(t *testing.T, player *Player) <-chan Event {
+	server, client := net.Pipe()
+	player.conn = server
+	t.Cleanup(func() { client.Close() })
+
+	events := make(chan Event, 64)
+	go func() {
+		defer close(events)
+		decoder := json.NewDecoder(client)
+		for {
+			var event Event
+			if decoder.Decode(&event) != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+	return events
+}
+
+func nextTestEvent(t *testing.T, events <-chan Event, code EventCode) Event {
+	select {
+	case event := <-events:
+		if event.Code != code {
+			t.Fatalf("Expected event %d, got %d", code, event.Code)
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("Event %d was not received", code)
+	}
+	return Event{}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "sort"
+
+// publish makes room listed in lobby. Room mutex has to be locked
+func (room *Room) publish() {
+	room.public = true
+	room.updateListing()
+}
+
+// updateListing refreshes room info shown in lobby. It's kept apart from room so rooms can be listed without locking them.
+// Room mutex has to be locked
+func (room *Room) updateListing() {
+	if !room.public {
+		return
+	}
+	room.listing.Store(&StocRoomInfo{
+		RoomUid:    room.uid,
+		HostName:   room.primary.name,
+		Players:    len(room.players()),
+		Locked:     room.locked(),
+		Joinable:   room.gamestate == INITIAL && !room.full(),
+		Gamestate_: room.gamestate,
+		Rules:      room.rules.toStoc(),
+		CreatedAt:  room.createdAt.UnixMilli(),
+	})
+}
+
+// listRooms returns page of public rooms, the newest first, and total count of public rooms
+func listRooms(page int, pageSize int) ([]StocRoomInfo, int) {
+	rooms := []StocRoomInfo{}
+	ROOMS_CONTAINER.Range(func(_, value any) bool {
+		if listing := value.(*Room).listing.Load(); listing != nil {
+			rooms = append(rooms, *listing)
+		}
+		return true
+	})
+
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].CreatedAt != rooms[j].CreatedAt {
+			return rooms[i].CreatedAt > rooms[j].CreatedAt
+		}
+		return rooms[i].RoomUid < rooms[j].RoomUid
+	})
+
+	total := len(rooms)
+	start := min(page*pageSize, total)
+	end := min(start+pageSize, total)
+	return rooms[start:end], total
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestListRooms(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	private := createRoom(newTestPlayer(cfg, "private"), defaultRules())
+	defer private.destroy()
+
+	var public []*Room
+	for _, name := range []string{"first", "second", "third"} {
+		room := createRoom(newTestPlayer(cfg, name), defaultRules())
+		defer room.destroy()
+		room.publish()
+		public = append(public, room)
+	}
+	public[0].addPlayer(newTestPlayer(cfg, "guest"))
+
+	rooms, total := listRooms(0, 10)
+	if total != 3 || len(rooms) != 3 {
+		t.Fatalf("Unexpected rooms count: %d", total)
+	}
+	for _, room := range rooms {
+		if room.RoomUid == private.uid {
+			t.Errorf("Private room was listed")
+		}
+		if room.RoomUid == public[0].uid && (room.Players != 2 || room.Gamestate_ != BUILDING || room.Joinable) {
+			t.Errorf("Room listing was not updated: %+v", room)
+		}
+		if room.RoomUid != public[0].uid && !room.Joinable {
+			t.Errorf("Room waiting for players is not joinable: %+v", room)
+		}
+	}
+
+	if rooms, _ := listRooms(1, 2); len(rooms) != 1 {
+		t.Errorf("Unexpected rooms count on the last page: %d", len(rooms))
+	}
+	if rooms, _ := listRooms(5, 2); len(rooms) != 0 {
+		t.Errorf("Rooms were listed out of range")
+	}
+
+	public[1].destroy()
+	if _, total := listRooms(0, 10); total != 2 {
+		t.Errorf("Destroyed room was listed")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Matchmaker keeps players looking for a match in a queue and periodically pairs them into new rooms.
+// Only players with close ratings are paired, acceptable rating gap widens the longer player waits.
+// Locking order: player mutex, then matchmaker mutex. Matchmaker never holds its mutex while locking players
+type Matchmaker struct {
+	mtx         sync.Mutex
+	interval    time.Duration
+	clock       Clock
+	queue       []*Player     // Ordered by queuedAt
+	averageWait time.Duration // Moving average of waiting time of matched players, zero if nobody was matched yet
+	stop        chan struct{}
+}
+
+func newMatchmaker(interval time.Duration, clock Clock) *Matchmaker {
+	return &Matchmaker{
+		interval: interval,
+		clock:    clock,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (mm *Matchmaker) start() {
+	go func() {
+		ticker := time.NewTicker(mm.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				mm.match()
+			case <-mm.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (mm *Matchmaker) close() {
+	close(mm.stop)
+}
+
+// enqueue puts player to the end of the queue. Player mutex has to be locked
+func (mm *Matchmaker) enqueue(player *Player) {
+	stats, _ := STATS_STORE.get(player.name)
+	player.queueRating = stats.Rating
+
+	mm.mtx.Lock()
+	defer mm.mtx.Unlock()
+
+	player.queuedAt = mm.clock.Now()
+	mm.queue = append(mm.queue, player)
+	mm.notify(len(mm.queue)-1, player.queuedAt)
+}
+
+// requeue puts player who was taken from the queue back to his place
+func (mm *Matchmaker) requeue(player *Player) {
+	mm.mtx.Lock()
+	defer mm.mtx.Unlock()
+
+	i := len(mm.queue)
+	for i > 0 && mm.queue[i-1].queuedAt.After(player.queuedAt) {
+		i--
+	}
+	mm.queue = append(mm.queue, nil)
+	copy(mm.queue[i+1:], mm.queue[i:])
+	mm.queue[i] = player
+}
+
+// dequeue removes player from the queue and reports if he was looking for a match. Player mutex has to be locked
+func (mm *Matchmaker) dequeue(player *Player) bool {
+	if !player.queued() {
+		return false
+	}
+	player.queuedAt = time.Time{}
+
+	mm.mtx.Lock()
+	defer mm.mtx.Unlock()
+
+	for i, queued := range mm.queue {
+		if queued == player {
+			mm.queue = append(mm.queue[:i], mm.queue[i+1:]...)
+			mm.notifyAll(mm.clock.Now())
+			break
+		}
+	}
+	return true // Player could be already taken from the queue by match(), he won't be matched anyway
+}
+
+// notify sends queue position and estimated wait to the player at index. Matchmaker mutex has to be locked
+func (mm *Matchmaker) notify(index int, now time.Time) {
+	player := mm.queue[index]
+	response := StocMatchQueue{
+		Position:      index + 1,
+		EstimatedWait: -1,
+	}
+	if mm.averageWait != 0 {
+		response.EstimatedWait = int(max(mm.averageWait-now.Sub(player.queuedAt), 0).Seconds())
+	}
+	player.send(FIND_MATCH, response)
+}
+
+func (mm *Matchmaker) notifyAll(now time.Time) {
+	for i := range mm.queue {
+		mm.notify(i, now)
+	}
+}
+
+// match pairs waiting players into new rooms and returns count of created rooms
+func (mm *Matchmaker) match() int {
+	now := mm.clock.Now()
+
+	mm.mtx.Lock()
+	var pairs [][2]*Player
+	paired := map[*Player]bool{}
+	for i, player := range mm.queue { // The longest waiting players are paired first
+		if paired[player] {
+			continue
+		}
+		for _, opponent := range mm.queue[i+1:] {
+			if !paired[opponent] && mm.canPair(player, opponent, now) {
+				pairs = append(pairs, [2]*Player{player, opponent})
+				paired[player] = true
+				paired[opponent] = true
+				break
+			}
+		}
+	}
+	queue := mm.queue[:0]
+	for _, player := range mm.queue {
+		if !paired[player] {
+			queue = append(queue, player)
+		}
+	}
+	mm.queue = queue
+	mm.mtx.Unlock()
+
+	created := 0
+	for _, pair := range pairs {
+		if mm.startMatch(pair[0], pair[1], now) {
+			created++
+		}
+	}
+
+	if len(pairs) != 0 {
+		mm.mtx.Lock()
+		mm.notifyAll(now)
+		mm.mtx.Unlock()
+	}
+	return created
+}
+
+// ratingGap returns maximal rating difference of opponent acceptable for the player
+func (mm *Matchmaker) ratingGap(player *Player, now time.Time) int {
+	return MATCHMAKING_RATING_GAP + int(now.Sub(player.queuedAt).Seconds()*MATCHMAKING_GAP_GROWTH)
+}
+
+// canPair reports if rating difference of players is acceptable for at least one of them
+func (mm *Matchmaker) canPair(player *Player, opponent *Player, now time.Time) bool {
+	diff := player.queueRating - opponent.queueRating
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= max(mm.ratingGap(player, now), mm.ratingGap(opponent, now))
+}
+
+// startMatch creates room for two players taken from the queue. If one of them has left the queue meanwhile, another one is put back
+func (mm *Matchmaker) startMatch(primary *Player, secondary *Player, now time.Time) bool {
+	primary.mtx.Lock()
+	defer primary.mtx.Unlock()
+	secondary.mtx.Lock()
+	defer secondary.mtx.Unlock()
+
+	if !primary.queued() || !secondary.queued() {
+		for _, player := range []*Player{primary, secondary} {
+			if player.queued() {
+				mm.requeue(player)
+			}
+		}
+		return false
+	}
+
+	mm.mtx.Lock()
+	for _, player := range []*Player{primary, secondary} {
+		wait := now.Sub(player.queuedAt)
+		if mm.averageWait == 0 {
+			mm.averageWait = wait
+		} else {
+			mm.averageWait = (mm.averageWait*(MATCHMAKING_WAIT_SAMPLES-1) + wait) / MATCHMAKING_WAIT_SAMPLES
+		}
+		player.queuedAt = time.Time{}
+	}
+	mm.mtx.Unlock()
+
+	room := createRoom(primary, defaultRules())
+	room.mtx.Lock()
+	defer room.mtx.Unlock()
+
+	primary.send(MATCH_FOUND, StocMatchFound{
+		RoomUid: room.uid,
+		Role:    PRIMARY,
+	})
+	secondary.send(MATCH_FOUND, StocMatchFound{
+		RoomUid: room.uid,
+		Role:    SECONDARY,
+	})
+	return room.addPlayer(secondary)
+}
+
+func (pl *Player) queued() bool {
+	return !pl.queuedAt.IsZero()
+}
+
+// Replaced with configured matchmaker in main()
+var MATCHMAKER = newMatchmaker(MATCHMAKING_INTERVAL, systemClock{})
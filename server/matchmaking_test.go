@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestMatchmaker(t *testing.T, clock Clock) *Matchmaker {
+	matchmaker := MATCHMAKER
+	t.Cleanup(func() { MATCHMAKER = matchmaker })
+
+	MATCHMAKER = newMatchmaker(MATCHMAKING_INTERVAL, clock)
+	return MATCHMAKER
+}
+
+func TestMatchmaking(t *testing.T) {
+	cfg := newTestConfig(t)
+	clock := &fakeClock{now: time.Now()}
+	matchmaker := newTestMatchmaker(t, clock)
+
+	primary := newTestPlayer(cfg, "primary")
+	secondary := newTestPlayer(cfg, "secondary")
+	matchmaker.enqueue(primary)
+	if matchmaker.match() != 0 || !primary.queued() {
+		t.Fatalf("Player was matched with nobody")
+	}
+
+	clock.advance(10 * time.Second)
+	matchmaker.enqueue(secondary)
+	if matchmaker.match() != 1 {
+		t.Fatalf("Waiting players were not matched")
+	}
+
+	room := primary.room
+	if room == nil || room != secondary.room || room.primary != primary || room.secondary != secondary {
+		t.Fatalf("Matched players were not put to the same room")
+	}
+	defer room.destroy()
+
+	if primary.queued() || secondary.queued() || len(matchmaker.queue) != 0 {
+		t.Errorf("Matched players are still in queue")
+	}
+	if !room.building() {
+		t.Errorf("Building was not started in matched room")
+	}
+	if matchmaker.averageWait <= 0 || matchmaker.averageWait > 10*time.Second {
+		t.Errorf("Unexpected average wait: %s", matchmaker.averageWait)
+	}
+}
+
+func TestMatchmakingCancel(t *testing.T) {
+	cfg := newTestConfig(t)
+	matchmaker := newTestMatchmaker(t, &fakeClock{now: time.Now()})
+
+	first := newTestPlayer(cfg, "first")
+	second := newTestPlayer(cfg, "second")
+	third := newTestPlayer(cfg, "third")
+	matchmaker.enqueue(first)
+	matchmaker.enqueue(second)
+	matchmaker.enqueue(third)
+
+	if !matchmaker.dequeue(second) || matchmaker.dequeue(second) {
+		t.Fatalf("Player was not removed from queue exactly once")
+	}
+	third.destroy()
+
+	if matchmaker.match() != 0 || !first.queued() || first.isInRoom() {
+		t.Errorf("Player was matched with someone who has left the queue")
+	}
+}
+
+func TestMatchmakingRatingGap(t *testing.T) {
+	cfg := newTestConfig(t)
+	clock := &fakeClock{now: time.Now()}
+	matchmaker := newTestMatchmaker(t, clock)
+
+	novice := newTestPlayer(cfg, "novice")
+	expert := newTestPlayer(cfg, "expert")
+	matchmaker.enqueue(novice)
+	matchmaker.enqueue(expert)
+	novice.queueRating = INITIAL_RATING
+	expert.queueRating = INITIAL_RATING + 2*MATCHMAKING_RATING_GAP
+
+	if matchmaker.match() != 0 {
+		t.Fatalf("Players with too different ratings were matched")
+	}
+
+	clock.advance(time.Duration(MATCHMAKING_RATING_GAP/MATCHMAKING_GAP_GROWTH) * time.Second)
+	if matchmaker.match() != 1 {
+		t.Fatalf("Rating gap was not widened over time")
+	}
+	novice.room.destroy()
+}
+
+func TestMatchedWhileRequesting(t *testing.T) {
+	cfg := newTestConfig(t)
+	matchmaker := newTestMatchmaker(t, systemClock{})
+
+	primary := newTestPlayer(cfg, "primary")
+	secondary := newTestPlayer(cfg, "secondary")
+	connectTestPlayer(t, primary)
+	matchmaker.enqueue(primary)
+	matchmaker.enqueue(secondary)
+
+	// Matchmaker puts player to room from its own goroutine while his request is being read
+	matched := make(chan int)
+	go func() {
+		matched <- matchmaker.match()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if !handleRequest(primary, json.NewDecoder(strings.NewReader(fmt.Sprintf(`{"code": %d}`, PING)))) {
+		t.Errorf("Request of matched player was rejected")
+	}
+	if <-matched != 1 {
+		t.Fatalf("Waiting players were not matched")
+	}
+
+	room := primary.currentRoom()
+	if room == nil {
+		t.Fatalf("Player was not put to room")
+	}
+	room.destroy()
+}
@@ -0,0 +1,84 @@
+package main
+
+import "math/rand"
+
+// revealRocks shows rocks of both players to everyone when game starts. Rocks count as shot at,
+// so they can't be shot at during the game. Room mutex has to be locked
+func (room *Room) revealRocks() {
+	for _, player := range room.players() {
+		for _, entity := range player.entities {
+			if entity.type_ != ROCK {
+				continue
+			}
+
+			entity.destroyAtAbs(entity.position) // Revealed rocks are shown as destroyed entities in snapshots
+			player.shotPoints = append(player.shotPoints, entity.position)
+			room.announce(ADD_ENTITY, newStocAddEntity(player.role, ROCK, entity.position, entity.direction))
+			if enemy := player.enemy(); enemy.bot != nil {
+				enemy.bot.observe(entity.position, SHOT_MISS, nil) // There is no ship to look for
+			}
+		}
+	}
+}
+
+// explodeMine punishes player who has shot at enemy mine. Room mutex has to be locked
+func (room *Room) explodeMine(shooter *Player, point Vec2) {
+	room.announce(MINE_EXPLODED, StocMineExploded{
+		Role:     shooter.role,
+		Effect:   room.rules.MineEffect,
+		Position: StocPoint{X: point.x, Y: point.y},
+	})
+	room.logInfo("%s has shot at mine", shooter.name)
+
+	switch room.rules.MineEffect {
+	case MINE_SKIP_TURN:
+		shooter.turnsToSkip++
+	case MINE_REVEAL_CELL:
+		board := shooter.board()
+		if point, ok := board.randomHiddenShipPoint(); ok {
+			board.revealedPoints = append(board.revealedPoints, point)
+			room.announce(ADD_ENTITY, newStocAddEntity(board.role, REVEALED_CELL, point, HORIZONTAL))
+		}
+	}
+}
+
+// randomHiddenShipPoint returns random cell of player ships which is neither hit nor revealed yet
+func (pl *Player) randomHiddenShipPoint() (Vec2, bool) {
+	var points []Vec2
+	for _, entity := range pl.entities {
+		if !entity.ship() {
+			continue
+		}
+		for _, point := range entity.absPoints() {
+			if !pl.isAlreadyShotAt(point) && !pl.isRevealed(point) {
+				points = append(points, point)
+			}
+		}
+	}
+
+	if len(points) == 0 {
+		return Vec2{}, false
+	}
+	return points[rand.Intn(len(points))], true
+}
+
+func (pl *Player) isRevealed(point Vec2) bool {
+	for _, thisPoint := range pl.revealedPoints {
+		if thisPoint.equals(point) {
+			return true
+		}
+	}
+	return false
+}
+
+// shotCellType returns how point of player battlefield which was shot at is drawn
+func (pl *Player) shotCellType(point Vec2) EntityType {
+	entity := pl.entityAt(point)
+	switch {
+	case entity == nil:
+		return EMPTY_CELL
+	case !entity.ship():
+		return entity.type_
+	}
+	return X_MARK
+}
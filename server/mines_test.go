@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func newMinesTestRoom(t *testing.T, effect MineEffect) *Room {
+	rules := defaultRules()
+	rules.Mines = 1
+	rules.Rocks = 1
+	rules.MineEffect = effect
+
+	room := newTestRoom(t, newTestConfig(t), rules)
+	for _, player := range room.players() {
+		player.placeEntity(MINE, Vec2{x: 10, y: 1}, HORIZONTAL)
+		player.placeEntity(ROCK, Vec2{x: 10, y: 10}, HORIZONTAL)
+		placeTestFleet(t, player)
+		if !player.built() {
+			t.Fatalf("Fleet with special entities is not built")
+		}
+	}
+	if !room.startPlaying() {
+		t.Fatalf("Could not start playing")
+	}
+	return room
+}
+
+func TestMineSkipTurn(t *testing.T) {
+	room := newMinesTestRoom(t, MINE_SKIP_TURN)
+	defer room.destroy()
+
+	if result := room.fire(room.primary, Vec2{x: 10, y: 10}); result != SHOT_INVALID {
+		t.Errorf("Rock was shot at: %d", result)
+	}
+	if result := room.fire(room.primary, Vec2{x: 10, y: 1}); result != SHOT_MINE {
+		t.Fatalf("Unexpected result of shot at mine: %d", result)
+	}
+	room.fire(room.secondary, Vec2{x: 9, y: 9})
+	if room.turn != SECONDARY {
+		t.Errorf("Player who has shot at mine has not skipped the turn")
+	}
+	room.fire(room.secondary, Vec2{x: 9, y: 8})
+	if room.turn != PRIMARY {
+		t.Errorf("Player who has shot at mine has skipped more than one turn")
+	}
+}
+
+func TestMineRevealCell(t *testing.T) {
+	room := newMinesTestRoom(t, MINE_REVEAL_CELL)
+	defer room.destroy()
+
+	room.fire(room.primary, Vec2{x: 10, y: 1})
+	if len(room.primary.revealedPoints) != 1 || room.primary.entityAt(room.primary.revealedPoints[0]) == nil {
+		t.Errorf("Ship cell was not revealed: %+v", room.primary.revealedPoints)
+	}
+	if room.turn != SECONDARY {
+		t.Errorf("Turn was not switched after shot at mine")
+	}
+}
+
+func TestSpecialEntitiesBots(t *testing.T) {
+	rules := defaultRules()
+	rules.Mines = 2
+	rules.Rocks = 2
+	rules.MineEffect = MINE_SKIP_TURN
+	for i := 0; i < 10; i++ {
+		if _, err := playOfflineGame(newTestConfig(t), rules, BOT_PROBABILITY, BOT_HUNT_TARGET); err != nil {
+			t.Fatalf("Could not play game with mines and rocks: %s", err)
+		}
+	}
+}
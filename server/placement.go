@@ -0,0 +1,177 @@
+package main
+
+import "errors"
+
+var errNoEntityAt = errors.New("there is no entity at specified point")
+
+// canEditFleet reports if player is allowed to change his fleet, sending error otherwise
+func (pl *Player) canEditFleet() bool {
+	if !pl.room.building() {
+		pl.unknownError("not in building stage")
+		return false
+	}
+	if pl.ready {
+		pl.unknownError("you've already built your battlefield")
+		return false
+	}
+	if !pl.ownsBoard() {
+		pl.unknownError("shared battlefield is built by your team leader")
+		return false
+	}
+	return true
+}
+
+// canCancelReady reports if player is allowed to take READY_TO_PLAY back, sending error otherwise.
+// It's too late once any enemy battlefield is built, as enemy could have seen the fleet is ready
+func (pl *Player) canCancelReady() bool {
+	if !pl.room.building() {
+		pl.unknownError("not in building stage")
+		return false
+	}
+	if !pl.ownsBoard() {
+		pl.unknownError("shared battlefield is built by your team leader")
+		return false
+	}
+	if !pl.ready {
+		pl.unknownError("you have not built your battlefield yet")
+		return false
+	}
+	for _, enemy := range pl.room.boards(opposingTeam(pl.role)) {
+		if enemy.ready {
+			pl.unknownError("your enemy has already built their battlefield")
+			return false
+		}
+	}
+	return true
+}
+
+func (pl *Player) removeEntity(entity *Entity) {
+	for i, thisEntity := range pl.entities {
+		if thisEntity == entity {
+			pl.entities = append(pl.entities[:i], pl.entities[i+1:]...)
+			return
+		}
+	}
+}
+
+func (pl *Player) rejectEntity(err error) {
+	pl.logInfo("Entity was rejected: %s", err)
+	pl.send(INVALID_ENTITY, StocInvalidEntity{
+		Error: err.Error(),
+	})
+}
+
+// placeEntity places single ship, replying with ADD_ENTITY or INVALID_ENTITY
+func (pl *Player) placeEntity(type_ EntityType, position Vec2, direction DirectionType) {
+	entity, err := newEntity(pl.rules(), type_, position, direction)
+	if err == nil {
+		err = pl.addEntity(entity)
+	}
+	if err != nil {
+		pl.rejectEntity(err)
+		return
+	}
+	pl.sendToTeam(ADD_ENTITY, newStocAddEntity(pl.role, type_, position, direction))
+}
+
+// removeEntityAt removes ship covering the point, replying with CLEAR_BATTLEFIELD or INVALID_ENTITY
+func (pl *Player) removeEntityAt(point Vec2) {
+	entity := pl.entityAt(point)
+	if entity == nil {
+		pl.rejectEntity(errNoEntityAt)
+		return
+	}
+	pl.removeEntity(entity)
+	pl.clearEntity(entity)
+}
+
+func (pl *Player) clearEntity(entity *Entity) {
+	for _, area := range entity.areas() {
+		pl.sendToTeam(CLEAR_BATTLEFIELD, newStocClearBattlefield(pl.role, area))
+	}
+}
+
+// moveEntity moves and rotates ship covering the point. Ship is kept where it was if new position is invalid
+func (pl *Player) moveEntity(from Vec2, position Vec2, direction DirectionType) {
+	entity := pl.entityAt(from)
+	if entity == nil {
+		pl.rejectEntity(errNoEntityAt)
+		return
+	}
+
+	moved, err := newEntity(pl.rules(), entity.type_, position, direction)
+	if err == nil {
+		pl.removeEntity(entity)
+		if err = pl.addEntity(moved); err != nil {
+			pl.entities = append(pl.entities, entity)
+		}
+	}
+	if err != nil {
+		pl.rejectEntity(err)
+		return
+	}
+
+	pl.clearEntity(entity)
+	pl.sendToTeam(ADD_ENTITY, newStocAddEntity(pl.role, moved.type_, moved.position, moved.direction))
+}
+
+// cancelReady takes READY_TO_PLAY back and clears the fleet, so player can build it again
+func (pl *Player) cancelReady() {
+	pl.ready = false
+	pl.clearEntities()
+	pl.announceToRoom(CANCEL_READY, StocCancelReady{
+		Role: pl.role,
+	})
+	pl.syncBattlefield(true)
+}
+
+// readyToPlay confirms the fleet sent with READY_TO_PLAY, or the one already placed on the server
+// (see AUTO_PLACE, PLACE_ENTITY) if none is sent. Game starts once everyone is ready
+func (pl *Player) readyToPlay(data *CtosReadyToPlay) {
+	fromServer := len(data.Entities) == 0
+	if !fromServer {
+		pl.clearEntities()
+	}
+	var lastError error
+	for _, entity := range data.Entities {
+		addingEntity, err := newEntity(
+			pl.rules(),
+			entity.Type_,
+			Vec2{x: entity.Position.X, y: entity.Position.Y},
+			entity.Direction,
+		)
+
+		if err != nil {
+			// Incorrect entity position/direction, probably a hack attempt
+			lastError = err
+			break
+		}
+
+		if err := pl.addEntity(addingEntity); err != nil {
+			// Invalid entity type/entities count limit exceeded/intersecting entities were found, probably a hack attempt
+			lastError = err
+			break
+		}
+	}
+
+	if lastError != nil {
+		pl.securityError(lastError.Error())
+		pl.clearEntities()
+	} else if pl.built() {
+		pl.announceReady()
+		pl.room.startPlaying()
+	} else {
+		pl.unknownError("not enough entities were placed")
+		if !fromServer {
+			pl.clearEntities()
+		}
+	}
+}
+
+// autoPlace replaces the fleet with a random one, which still has to be confirmed with READY_TO_PLAY
+func (pl *Player) autoPlace() {
+	if err := pl.placeRandomFleet(); err != nil {
+		pl.unknownError("%s", err)
+	}
+	pl.syncBattlefield(false)
+}
@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+func TestIncrementalPlacement(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := createRoom(newTestPlayer(cfg, "primary"), defaultRules())
+	defer room.destroy()
+	room.addPlayer(newTestPlayer(cfg, "secondary"))
+	player := room.primary
+
+	player.placeEntity(FOURDECK, Vec2{x: 1, y: 1}, HORIZONTAL)
+	player.placeEntity(THREEDECK, Vec2{x: 1, y: 2}, HORIZONTAL) // Touches four-deck ship
+	player.placeEntity(FOURDECK, Vec2{x: 1, y: 5}, HORIZONTAL)  // Exceeds count limit
+	if len(player.entities) != 1 {
+		t.Fatalf("Invalid entities were placed: %d entities", len(player.entities))
+	}
+
+	player.placeEntity(THREEDECK, Vec2{x: 1, y: 3}, HORIZONTAL)
+	player.moveEntity(Vec2{x: 2, y: 3}, Vec2{x: 1, y: 4}, VERTICAL) // Touches four-deck ship
+	if entity := player.entityAt(Vec2{x: 3, y: 3}); entity == nil || entity.direction != HORIZONTAL {
+		t.Errorf("Ship was not kept in place after invalid move")
+	}
+	player.moveEntity(Vec2{x: 2, y: 3}, Vec2{x: 10, y: 3}, VERTICAL)
+	if player.entityAt(Vec2{x: 1, y: 3}) != nil || player.entityAt(Vec2{x: 10, y: 1}) == nil || len(player.entities) != 2 {
+		t.Errorf("Ship was not moved")
+	}
+
+	player.removeEntityAt(Vec2{x: 4, y: 1})
+	player.removeEntityAt(Vec2{x: 5, y: 5})
+	if len(player.entities) != 1 || player.entityAt(Vec2{x: 1, y: 1}) != nil {
+		t.Errorf("Ship was not removed")
+	}
+}
+
+func TestCancelReady(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := createRoom(newTestPlayer(cfg, "primary"), defaultRules())
+	defer room.destroy()
+	room.addPlayer(newTestPlayer(cfg, "secondary"))
+
+	if room.primary.canCancelReady() {
+		t.Errorf("Ready was cancelled before battlefield is built")
+	}
+	placeTestFleet(t, room.primary)
+	if !room.primary.canCancelReady() {
+		t.Fatalf("Could not cancel ready while enemy is building")
+	}
+	room.primary.cancelReady()
+	if room.primary.ready || len(room.primary.entities) != 0 {
+		t.Fatalf("Fleet was not cleared after cancelling ready")
+	}
+
+	placeTestFleet(t, room.secondary)
+	if room.startPlaying() {
+		t.Fatalf("Game has started while primary player is not ready")
+	}
+	placeTestFleet(t, room.primary)
+	if room.primary.canCancelReady() {
+		t.Errorf("Ready was cancelled after enemy is ready")
+	}
+	if !room.startPlaying() {
+		t.Errorf("Could not start playing after building fleet again")
+	}
+}
+
+func TestCancelReadySharedBoard(t *testing.T) {
+	rules := defaultRules()
+	rules.Teams = true
+	rules.SharedBoards = true
+	room := newTestRoom(t, newTestConfig(t), rules)
+	defer room.destroy()
+
+	placeTestFleet(t, room.primary)
+	room.tertiary.ready = true // Teammates never get ready, but they must not cancel leader battlefield either way
+	if room.tertiary.canCancelReady() {
+		t.Errorf("Teammate has cancelled ready of shared battlefield")
+	}
+	if !room.primary.canCancelReady() {
+		t.Errorf("Team leader could not cancel ready")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"sync"
 	"time"
@@ -17,28 +18,67 @@ const (
 	SECONDARY PlayerRoleType = 2
 )
 
+type ShotResult int
+
+const (
+	SHOT_INVALID ShotResult = 0 // Point is out of battlefield or was already shot at
+	SHOT_MISS    ShotResult = 1
+	SHOT_HIT     ShotResult = 2
+	SHOT_SUNK    ShotResult = 3
+	SHOT_MINE    ShotResult = 4 // Shooter is punished, see MineEffect
+)
+
 type Player struct {
 	mtx                 sync.Mutex
+	cfg                 *Config
 	remoteAddr          string
 	name                string
+	authenticated       bool // Player has logged in, so name is his account nickname
 	connectedAt         time.Time
+	connMtx             sync.Mutex // Guards conn, events are sent from room goroutines and delayed events goroutine
 	conn                net.Conn
 	entities            []*Entity
 	shotPoints          []Vec2
+	roomMtx             sync.Mutex // Guards writes of room, so it can be read before room mutex is locked, see currentRoom
 	room                *Room
 	role                PlayerRoleType
 	securityErrorsCount int
 	revengeRequested    bool
+	ready               bool // Player has confirmed his fleet with READY_TO_PLAY
 	lastEventTime       time.Time
 	eventsCount         int
+	missedTurns         int                // Turns in a row skipped by the shot clock
+	turnsToSkip         int                // Turns player has to miss for shooting at mines, see MINE_SKIP_TURN
+	revealedPoints      []Vec2             // Cells of player ships revealed to enemy by mines, see MINE_REVEAL_CELL
+	weapons             map[WeaponType]int // Uses of weapons left in the current game
+	target              PlayerRoleType     // Opponent chosen to shoot at in team room, see targetEnemy
+	shots               int                // Statistics of the current game
+	hits                int
+	shipsSunk           int
+	sessionToken        string
+	quit                bool      // Player has sent DISCONNECT, so his slot is not kept after connection is closed
+	connectionLostAt    time.Time // Zero if connected
+	queuedAt            time.Time // Zero if player is not looking for a match
+	queueRating         int       // Rating at the moment player was queued
+	resumedAs           *Player   // Slot player has resumed; connection is handled on behalf of it since then
+	fullReveal          bool      // Spectator sees every ship, see Room.addSpectator
+	revealDelay         time.Duration
+	delayedEvents       chan delayedEvent
+	delayedDone         chan struct{} // Closed once delayed events must not be sent anymore
+	bot                 Strategy      // Nil if player is human
+}
+
+func (pl *Player) rules() *Rules {
+	return &pl.room.rules
 }
 
 func (pl *Player) built() bool {
-	return len(pl.entities) == maxPlaceableShipsCount()
+	return len(pl.entities) == pl.rules().maxPlaceableEntitiesCount()
 }
 
 func (pl *Player) availableEntityTypeCount(type_ EntityType) int {
-	count := ENTITY_COUNT[type_]
+	rule, _ := pl.rules().entityRule(type_)
+	count := rule.Count
 	for _, entity := range pl.entities {
 		if entity.type_ == type_ {
 			count--
@@ -52,7 +92,11 @@ func (pl *Player) availableEntityTypeCount(type_ EntityType) int {
 
 func (pl *Player) findIntersectingEntity(entity Entity) *Entity {
 	for _, thisEntity := range pl.entities {
-		if thisEntity.intersects(entity) {
+		if pl.rules().AllowTouching {
+			if thisEntity.overlaps(entity) {
+				return thisEntity
+			}
+		} else if thisEntity.intersects(entity) {
 			return thisEntity
 		}
 	}
@@ -60,7 +104,7 @@ func (pl *Player) findIntersectingEntity(entity Entity) *Entity {
 }
 
 func (pl *Player) addEntity(entity Entity) error {
-	if !canPlaceEntityType(entity.type_) {
+	if !pl.rules().canPlaceEntityType(entity.type_) {
 		return fmt.Errorf("entities with specified type: %d cant be placed", entity.type_)
 	}
 	if pl.availableEntityTypeCount(entity.type_) <= 0 {
@@ -75,9 +119,18 @@ func (pl *Player) addEntity(entity Entity) error {
 }
 
 func (pl *Player) clearEntities() {
-	// self.entities = make([]Entity, maxPlaceableShipsCount())
 	pl.entities = nil
 	pl.shotPoints = nil
+	pl.revealedPoints = nil
+}
+
+func (pl *Player) entityAt(point Vec2) *Entity {
+	for _, entity := range pl.entities {
+		if _, err := entity.convertAbsPointToLocal(point); err == nil {
+			return entity
+		}
+	}
+	return nil
 }
 
 func (pl *Player) isAlreadyShotAt(point Vec2) bool {
@@ -89,13 +142,19 @@ func (pl *Player) isAlreadyShotAt(point Vec2) bool {
 	return false
 }
 
-func (pl *Player) shotAt(point Vec2) bool {
-	if !pl.isInRoom() || !pl.room.playing() || pl.isAlreadyShotAt(point) {
-		return false
+func (pl *Player) shotAt(point Vec2) ShotResult {
+	if !pl.isInRoom() || !pl.room.playing() || !pl.rules().inBounds(point) || pl.isAlreadyShotAt(point) {
+		return SHOT_INVALID
 	}
 
 	pl.shotPoints = append(pl.shotPoints, point)
 
+	if entity := pl.entityAt(point); entity != nil && entity.type_ == MINE {
+		entity.destroyAtAbs(point)
+		pl.announceToRoom(ADD_ENTITY, newStocAddEntity(pl.role, MINE, point, HORIZONTAL))
+		return SHOT_MINE
+	}
+
 	sendEvent := StocAddEntity{
 		Role: pl.role,
 	}
@@ -109,45 +168,36 @@ func (pl *Player) shotAt(point Vec2) bool {
 		if entity.destroyAtAbs(point) {
 			destroyed = true
 			if entity.destroyed() {
-				{ // Drawing grey empty cells
-					entityDimensions := entity.dimensions()
-					entityDimensions.start.sub(1)
-					entityDimensions.end.add(1)
-					for x := entityDimensions.start.x; x <= entityDimensions.end.x; x++ {
-						for y := entityDimensions.start.y; y <= entityDimensions.end.y; y++ {
-							if !(x >= 1 && x <= 10 && y >= 1 && y <= 10) {
-								continue
-							}
+				if !pl.rules().AllowTouching { // Drawing grey empty cells
+					for _, thisPoint := range entity.haloPoints() {
+						if !pl.rules().inBounds(thisPoint) {
+							continue
+						}
 
-							thisPoint := Vec2{x: x, y: y}
-							if !pl.isAlreadyShotAt(thisPoint) {
-								sendEvent := StocAddEntity{
-									Role: pl.role,
-								}
+						if !pl.isAlreadyShotAt(thisPoint) {
+							sendEvent := StocAddEntity{
+								Role: pl.role,
+							}
 
-								sendEvent.Entity.Type_ = EMPTY_CELL
-								sendEvent.Entity.Position.X = thisPoint.x
-								sendEvent.Entity.Position.Y = thisPoint.y
-								sendEvent.Entity.Direction = HORIZONTAL
+							sendEvent.Entity.Type_ = EMPTY_CELL
+							sendEvent.Entity.Position.X = thisPoint.x
+							sendEvent.Entity.Position.Y = thisPoint.y
+							sendEvent.Entity.Direction = HORIZONTAL
 
-								pl.shotPoints = append(pl.shotPoints, thisPoint)
+							pl.shotPoints = append(pl.shotPoints, thisPoint)
 
-								pl.announceToRoom(ADD_ENTITY, sendEvent)
-							}
+							pl.announceToRoom(ADD_ENTITY, sendEvent)
 						}
 					}
 				}
 
-				{ // Remove any entities located at our entity position
-					entityDimensions := entity.dimensions()
-					sendEvent := StocClearBattlefield{
-						Role: pl.role,
+				// Remove any entities located at our entity position
+				for _, area := range entity.areas() {
+					sendEvent := newStocClearBattlefield(pl.role, area)
+					for _, opponent := range pl.opponents() {
+						opponent.send(CLEAR_BATTLEFIELD, sendEvent)
 					}
-					sendEvent.Start.X = entityDimensions.start.x
-					sendEvent.Start.Y = entityDimensions.start.y
-					sendEvent.End.X = entityDimensions.end.x
-					sendEvent.End.Y = entityDimensions.end.y
-					pl.enemy().send(CLEAR_BATTLEFIELD, sendEvent)
+					pl.room.announceToSpectators(CLEAR_BATTLEFIELD, sendEvent, false)
 				}
 
 				{ // Add entity to map itself for enemy
@@ -160,14 +210,17 @@ func (pl *Player) shotAt(point Vec2) bool {
 					sendEvent.Entity.Position.Y = entity.position.y
 					sendEvent.Entity.Direction = entity.direction
 
-					pl.enemy().send(ADD_ENTITY, sendEvent)
+					for _, opponent := range pl.opponents() {
+						opponent.send(ADD_ENTITY, sendEvent)
+					}
+					pl.room.announceToSpectators(ADD_ENTITY, sendEvent, false)
 				}
 
-				{ // Let this player let know that his ship was destroyed
+				{ // Let this player (and his team) know that his ship was destroyed
 					sendEvent.Entity.Type_ = X_MARK
-					pl.send(ADD_ENTITY, sendEvent)
+					pl.sendToTeam(ADD_ENTITY, sendEvent)
 				}
-				return false // do not switch sides
+				return SHOT_SUNK
 			}
 			break
 		}
@@ -179,12 +232,37 @@ func (pl *Player) shotAt(point Vec2) bool {
 		sendEvent.Entity.Type_ = X_MARK
 	}
 	pl.announceToRoom(ADD_ENTITY, sendEvent)
-	return !destroyed // we should not switch turn if we made a correct shot
+	if !destroyed {
+		return SHOT_MISS
+	}
+	return SHOT_HIT
+}
+
+func (pl *Player) notShotPoints() []Vec2 {
+	rules := pl.rules()
+	var points []Vec2
+	for x := 1; x <= rules.Width; x++ {
+		for y := 1; y <= rules.Height; y++ {
+			point := Vec2{x: x, y: y}
+			if !pl.isAlreadyShotAt(point) {
+				points = append(points, point)
+			}
+		}
+	}
+	return points
+}
+
+func (pl *Player) randomNotShotPoint() (Vec2, bool) {
+	points := pl.notShotPoints()
+	if len(points) == 0 {
+		return Vec2{}, false
+	}
+	return points[rand.Intn(len(points))], true
 }
 
 func (pl *Player) isTotallyDead() bool {
 	for _, entity := range pl.entities {
-		if !entity.destroyed() {
+		if entity.ship() && !entity.destroyed() {
 			return false
 		}
 	}
@@ -195,6 +273,9 @@ func (pl *Player) enemy() *Player {
 	if !pl.isInRoom() {
 		return nil
 	}
+	if pl.rules().Teams {
+		return pl.targetEnemy()
+	}
 
 	enemy := pl.room.primary
 	if pl.role == PRIMARY {
@@ -214,10 +295,14 @@ func (pl *Player) logPanic(format string, args ...any) {
 }
 
 func (pl *Player) send(code EventCode, data any) {
-	if pl.conn == nil {
+	if pl.connection() == nil {
 		return
 	}
+	pl.write(code, pl.encodeEvent(code, data))
+}
 
+// encodeEvent marshals event to a single line as it is sent
+func (pl *Player) encodeEvent(code EventCode, data any) []byte {
 	response := Event{}
 	response.Code = code
 	if data != nil {
@@ -232,10 +317,44 @@ func (pl *Player) send(code EventCode, data any) {
 	if err != nil {
 		pl.logPanic("%s", err)
 	}
-	responseStr = append(responseStr, '\n')
+	return append(responseStr, '\n')
+}
+
+func (pl *Player) write(code EventCode, line []byte) {
+	pl.connMtx.Lock()
+	defer pl.connMtx.Unlock()
+	pl.writeLocked(code, line)
+}
+
+// writeLocked sends encoded event, connMtx has to be locked
+func (pl *Player) writeLocked(code EventCode, line []byte) {
+	if pl.conn == nil {
+		return
+	}
 
 	pl.conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
-	pl.conn.Write(responseStr)
+	if _, err := pl.conn.Write(line); err != nil {
+		// Client may get desynced, it can recover using REQUEST_STATE
+		pl.logInfo("Could not send event %d: %s", code, err)
+	}
+}
+
+func (pl *Player) connection() net.Conn {
+	pl.connMtx.Lock()
+	defer pl.connMtx.Unlock()
+	return pl.conn
+}
+
+// takeConnection moves connection of another player to this one
+func (pl *Player) takeConnection(from *Player) {
+	from.connMtx.Lock()
+	conn := from.conn
+	from.conn = nil
+	from.connMtx.Unlock()
+
+	pl.connMtx.Lock()
+	pl.conn = conn
+	pl.connMtx.Unlock()
 }
 
 func (pl *Player) unknownError(format string, args ...any) {
@@ -254,7 +373,7 @@ func (pl *Player) securityError(format string, args ...any) {
 	})
 
 	pl.securityErrorsCount++
-	if MAX_SECURITY_ERRORS_COUNT != 0 && pl.securityErrorsCount >= MAX_SECURITY_ERRORS_COUNT {
+	if pl.cfg.MaxSecurityErrorsCount != 0 && pl.securityErrorsCount >= pl.cfg.MaxSecurityErrorsCount {
 		pl.disconnect()
 	}
 }
@@ -263,15 +382,91 @@ func (pl *Player) destroy() {
 	pl.mtx.Lock()
 	defer pl.mtx.Unlock()
 
-	if pl.isInRoom() {
-		pl.room.announce(PLAYER_DISCONNECTED, StocPlayerDisconnected{
-			Role: pl.role,
+	MATCHMAKER.dequeue(pl)
+
+	room := pl.currentRoom()
+	if room == nil {
+		pl.disconnect()
+		return
+	}
+
+	room.mtx.Lock()
+	if pl.room != room || !room.valid() { // Room was destroyed while we were waiting for its mutex
+		room.mtx.Unlock()
+		pl.disconnect()
+		return
+	}
+
+	if pl.spectating() {
+		room.removeSpectator(pl)
+		room.mtx.Unlock()
+		pl.disconnect()
+		return
+	}
+
+	if pl.canKeepSlot() {
+		pl.disconnect()
+		pl.connectionLostAt = time.Now()
+		room.announce(PLAYER_CONNECTION_LOST, StocPlayerConnectionLost{
+			Role:        pl.role,
+			GracePeriod: int(pl.cfg.ReconnectGracePeriod.Seconds()),
 		})
-		pl.room.destroy()
+		room.logInfo("%s has lost connection, keeping the slot for %s", pl.name, pl.cfg.ReconnectGracePeriod)
+		room.mtx.Unlock()
+		return
 	}
+
+	room.announce(PLAYER_DISCONNECTED, StocPlayerDisconnected{
+		Role: pl.role,
+	})
+	room.mtx.Unlock()
+	room.destroy()
 	pl.disconnect()
 }
 
+// announceReady lets room know that player has built his battlefield
+func (pl *Player) announceReady() {
+	pl.ready = true
+	pl.announceToRoom(READY_TO_PLAY, StocPlayerReadyToPlay{
+		Role: pl.role,
+	})
+
+	// Let's sync player battlefield with the server data (and show it to full reveal spectators)
+	pl.syncBattlefield(true)
+}
+
+// syncBattlefield sends player (and his team) his battlefield as it is stored on the server
+func (pl *Player) syncBattlefield(revealToSpectators bool) {
+	{
+		sendEvent := StocClearBattlefield{
+			Role: pl.role,
+		}
+		sendEvent.Start.X = 1
+		sendEvent.Start.Y = 1
+		sendEvent.End.X = pl.rules().Width
+		sendEvent.End.Y = pl.rules().Height
+
+		pl.sendToTeam(CLEAR_BATTLEFIELD, sendEvent)
+		if revealToSpectators {
+			pl.room.announceToSpectators(CLEAR_BATTLEFIELD, sendEvent, true)
+		}
+	}
+
+	for _, entity := range pl.entities {
+		event := StocAddEntity{
+			Role: pl.role,
+		}
+		event.Entity.Type_ = entity.type_
+		event.Entity.Position.X = entity.position.x
+		event.Entity.Position.Y = entity.position.y
+		event.Entity.Direction = entity.direction
+		pl.sendToTeam(ADD_ENTITY, event)
+		if revealToSpectators {
+			pl.room.announceToSpectators(ADD_ENTITY, event, true)
+		}
+	}
+}
+
 func (pl *Player) announceToRoom(code EventCode, data any) error {
 	if !pl.isInRoom() {
 		return errors.New("player is not in any room")
@@ -284,16 +479,32 @@ func (pl *Player) canMakeMove() bool {
 	return pl.isInRoom() && pl.room.turn == pl.role
 }
 
+func (pl *Player) setRoom(room *Room) {
+	pl.roomMtx.Lock()
+	pl.room = room
+	pl.roomMtx.Unlock()
+}
+
+// currentRoom returns room of the player when room mutex is not locked yet. Room has to be checked
+// once its mutex is locked, it may be destroyed in between
+func (pl *Player) currentRoom() *Room {
+	pl.roomMtx.Lock()
+	defer pl.roomMtx.Unlock()
+	return pl.room
+}
+
 func (pl *Player) isInRoom() bool {
 	return pl.room != nil
 }
 
 func (pl *Player) disconnect() {
+	pl.connMtx.Lock()
+	defer pl.connMtx.Unlock()
 	if pl.conn == nil {
 		return
 	}
 
-	pl.send(DISCONNECT, nil)
+	pl.writeLocked(DISCONNECT, pl.encodeEvent(DISCONNECT, nil))
 	pl.conn.Close()
 	pl.conn = nil
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Reaper periodically destroys rooms which timeouts or reconnection grace periods were exceeded, even if nobody in room sends any events.
+// It also runs the shot clock, skipping turns of players who have not made a move in time
+type Reaper struct {
+	interval time.Duration
+	clock    Clock
+	stop     chan struct{}
+}
+
+func newReaper(interval time.Duration, clock Clock) *Reaper {
+	return &Reaper{
+		interval: interval,
+		clock:    clock,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (reaper *Reaper) start() {
+	go func() {
+		ticker := time.NewTicker(reaper.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				reaper.sweep()
+			case <-reaper.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (reaper *Reaper) close() {
+	close(reaper.stop)
+}
+
+// sweep destroys all timed out rooms and returns their count
+func (reaper *Reaper) sweep() int {
+	now := reaper.clock.Now()
+
+	var rooms []*Room
+	ROOMS_CONTAINER.Range(func(_, value any) bool {
+		rooms = append(rooms, value.(*Room))
+		return true
+	})
+
+	destroyed := 0
+	for _, room := range rooms {
+		room.mtx.Lock()
+		expired := room.valid() && room.isTimeoutExceeded(now)
+		if expired {
+			room.announce(GAMEPLAY_TIMEOUT_EXCEEDED, nil)
+		} else if lost := room.lostPlayer(now); room.valid() && lost != nil {
+			room.announce(PLAYER_DISCONNECTED, StocPlayerDisconnected{
+				Role: lost.role,
+			})
+			expired = true
+		} else if room.valid() && room.isTurnTimeoutExceeded(now) {
+			room.turnTimeoutExceeded()
+		}
+		room.mtx.Unlock()
+
+		if expired {
+			room.logInfo("Timeout exceeded in gamestate %d", room.gamestate)
+			room.destroy()
+			destroyed++
+		}
+	}
+
+	if destroyed != 0 {
+		log.Printf("Reaper: destroyed %d timed out room(s)\n", destroyed)
+	}
+	return destroyed
+}
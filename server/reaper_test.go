@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReaperInitialTimeout(t *testing.T) {
+	cfg := newTestConfig(t)
+	clock := &fakeClock{now: time.Now()}
+	reaper := newReaper(cfg.ReaperInterval, clock)
+
+	player := newTestPlayer(cfg, "primary")
+	room := createRoom(player, defaultRules())
+	defer room.destroy()
+
+	clock.advance(cfg.InitialTimeout - time.Second)
+	if reaper.sweep() != 0 || !room.valid() {
+		t.Errorf("Room was destroyed before initial timeout")
+	}
+
+	clock.advance(2 * time.Second)
+	if reaper.sweep() != 1 || room.valid() {
+		t.Errorf("Room was not destroyed after initial timeout")
+	}
+
+	if player.isInRoom() {
+		t.Errorf("Player is still in destroyed room")
+	}
+}
+
+func TestReaperGameplayTimeout(t *testing.T) {
+	cfg := newTestConfig(t)
+	clock := &fakeClock{now: time.Now()}
+	reaper := newReaper(cfg.ReaperInterval, clock)
+
+	room := createRoom(newTestPlayer(cfg, "primary"), defaultRules())
+	defer room.destroy()
+	room.addPlayer(newTestPlayer(cfg, "secondary"))
+	room.setGamestate(PLAYING)
+
+	clock.advance(cfg.BuildingTimeout + time.Second)
+	if reaper.sweep() != 0 || !room.valid() {
+		t.Errorf("Playing room was destroyed by building timeout")
+	}
+
+	clock.advance(cfg.GameplayTimeout)
+	if reaper.sweep() != 1 || room.valid() {
+		t.Errorf("Room was not destroyed after gameplay timeout")
+	}
+}
+
+func TestReaperOverTimeout(t *testing.T) {
+	cfg := newTestConfig(t)
+	clock := &fakeClock{now: time.Now()}
+	reaper := newReaper(cfg.ReaperInterval, clock)
+
+	room := createRoom(newTestPlayer(cfg, "primary"), defaultRules())
+	defer room.destroy()
+	room.addPlayer(newTestPlayer(cfg, "secondary"))
+	room.setGamestate(OVER)
+
+	clock.advance(cfg.RevengeRequestTimeout + time.Second)
+	if reaper.sweep() != 1 || room.valid() {
+		t.Errorf("Room was not destroyed after revenge request timeout")
+	}
+}
+
+func TestShotClockPass(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.TurnTimeout = 30 * time.Second
+	cfg.TurnTimeoutAction = TURN_TIMEOUT_ACTION_PASS
+	cfg.MaxMissedTurns = 2
+	clock := &fakeClock{now: time.Now()}
+	reaper := newReaper(cfg.ReaperInterval, clock)
+
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+	primary := room.primary
+
+	clock.advance(cfg.TurnTimeout - time.Second)
+	reaper.sweep()
+	if room.turn != PRIMARY {
+		t.Errorf("Turn was passed before turn deadline")
+	}
+
+	clock.advance(2 * time.Second)
+	reaper.sweep()
+	if room.turn != SECONDARY || primary.missedTurns != 1 {
+		t.Errorf("Turn was not passed after turn deadline")
+	}
+
+	clock.advance(cfg.TurnTimeout + time.Second)
+	reaper.sweep()
+	if room.turn != PRIMARY {
+		t.Errorf("Turn was not passed back after turn deadline")
+	}
+
+	clock.advance(cfg.TurnTimeout + time.Second)
+	reaper.sweep()
+	if !room.over() {
+		t.Errorf("Game is not over after too many missed turns")
+	}
+}
+
+func TestShotClockRandomShot(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.TurnTimeout = 30 * time.Second
+	cfg.TurnTimeoutAction = TURN_TIMEOUT_ACTION_SHOT
+	clock := &fakeClock{now: time.Now()}
+	reaper := newReaper(cfg.ReaperInterval, clock)
+
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	clock.advance(cfg.TurnTimeout + time.Second)
+	reaper.sweep()
+	if len(room.secondary.shotPoints) == 0 {
+		t.Errorf("Random shot was not made on behalf of player after turn deadline")
+	}
+}
+
+func TestRequestToDestroyedRoom(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	player := room.primary
+	connectTestPlayer(t, player)
+	decoder := json.NewDecoder(strings.NewReader(fmt.Sprintf(`{"code": %d, "data": {"x": 1, "y": 1}}`, SHOT_AT)))
+
+	// Room is destroyed (e.g. by reaper) while request is waiting for room mutex.
+	// Waiters get the mutex in order once they have waited for long enough
+	room.mtx.Lock()
+	destroyed := make(chan struct{})
+	go func() {
+		room.destroy()
+		close(destroyed)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	handled := make(chan bool)
+	go func() {
+		handled <- handleRequest(player, decoder)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	room.mtx.Unlock()
+
+	<-destroyed
+	if <-handled {
+		t.Errorf("Request to destroyed room was handled")
+	}
+}
+
+func TestConcurrentJoins(t *testing.T) {
+	cfg := newTestConfig(t)
+	rules := defaultRules()
+	rules.Teams = true
+	room := createRoom(newTestPlayer(cfg, "primary"), rules)
+	defer room.destroy()
+	reaper := newReaper(cfg.ReaperInterval, systemClock{})
+
+	joined := make(chan *Player)
+	for i := 0; i < 6; i++ {
+		player := newTestPlayer(cfg, fmt.Sprintf("guest%d", i))
+		connectTestPlayer(t, player)
+		request, _ := json.Marshal(Event{Code: JOIN_ROOM, Data: mustMarshal(t, CtosJoinRoom{
+			Nickname: player.name,
+			RoomUid:  room.uid,
+			Version:  CLIENT_VERSION_REQUIRED,
+		})})
+		go func() {
+			handleRequest(player, json.NewDecoder(strings.NewReader(string(request))))
+			joined <- player
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		reaper.sweep()
+		<-joined
+	}
+
+	room.mtx.Lock()
+	defer room.mtx.Unlock()
+	if !room.full() || !room.building() {
+		t.Errorf("Room has not been filled with concurrent joins")
+	}
+	roles := map[PlayerRoleType]bool{}
+	for _, player := range room.players() {
+		if roles[player.role] || player.room != room {
+			t.Errorf("Slot %d was taken twice", player.role)
+		}
+		roles[player.role] = true
+	}
+}
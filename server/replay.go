@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type ReplayRecordKind string
+
+const (
+	REPLAY_START ReplayRecordKind = "start" // Rules and players nicknames
+	REPLAY_PLACE ReplayRecordKind = "place" // Entity placed by player
+	REPLAY_TURN  ReplayRecordKind = "turn"  // Turn switched to player
+	REPLAY_SHOT  ReplayRecordKind = "shot"  // Player shot at enemy battlefield
+	REPLAY_WIN   ReplayRecordKind = "win"   // Player won the battle
+)
+
+type ReplayEntity struct {
+	Type_     EntityType    `json:"type"`
+	Position  StocPoint     `json:"position"`
+	Direction DirectionType `json:"direction"`
+}
+
+// ReplayRecord is a single line of replay file
+type ReplayRecord struct {
+	Time           int64            `json:"time"` // Unix time in milliseconds
+	Kind           ReplayRecordKind `json:"kind"`
+	Role           PlayerRoleType   `json:"role,omitempty"`
+	Rules          json.RawMessage  `json:"rules,omitempty"` // Same as StocRules, so it can be read as CtosRules
+	PrimaryName    string           `json:"primaryName,omitempty"`
+	SecondaryName  string           `json:"secondaryName,omitempty"`
+	TertiaryName   string           `json:"tertiaryName,omitempty"`   // Team rooms only
+	QuaternaryName string           `json:"quaternaryName,omitempty"` // Team rooms only
+	Target         PlayerRoleType   `json:"target,omitempty"`         // Battlefield shot at in team rooms, see Player.targetEnemy
+	Entity         *ReplayEntity    `json:"entity,omitempty"`
+	Position       *StocPoint       `json:"position,omitempty"`
+	Result         ShotResult       `json:"result,omitempty"`
+	Forfeit        bool             `json:"forfeit,omitempty"`
+}
+
+// ReplayRecorder keeps records of the game being played in room
+type ReplayRecorder struct {
+	dir     string
+	games   int
+	records []ReplayRecord
+}
+
+func newReplayRecorder(dir string) *ReplayRecorder {
+	if dir == "" {
+		return nil
+	}
+	return &ReplayRecorder{dir: dir}
+}
+
+func (room *Room) record(record ReplayRecord) {
+	if room.recorder == nil {
+		return
+	}
+	record.Time = time.Now().UnixMilli()
+	room.recorder.records = append(room.recorder.records, record)
+}
+
+// recordStart starts recording of a new game, fleets have to be already built
+func (room *Room) recordStart() {
+	if room.recorder == nil {
+		return
+	}
+	room.recorder.games++
+	room.recorder.records = nil
+
+	rules, err := json.Marshal(room.rules.toStoc())
+	if err != nil {
+		room.logInfo("Could not record rules: %s", err)
+		return
+	}
+	start := ReplayRecord{
+		Kind:          REPLAY_START,
+		Rules:         rules,
+		PrimaryName:   room.primary.name,
+		SecondaryName: room.secondary.name,
+	}
+	if room.rules.Teams {
+		start.TertiaryName = room.tertiary.name
+		start.QuaternaryName = room.quaternary.name
+	}
+	room.record(start)
+
+	for _, player := range room.players() {
+		for _, entity := range player.entities {
+			room.record(ReplayRecord{
+				Kind: REPLAY_PLACE,
+				Role: player.role,
+				Entity: &ReplayEntity{
+					Type_:     entity.type_,
+					Position:  StocPoint{X: entity.position.x, Y: entity.position.y},
+					Direction: entity.direction,
+				},
+			})
+		}
+	}
+}
+
+// saveReplay writes recorded game to the replays directory as JSON lines
+func (room *Room) saveReplay() {
+	if room.recorder == nil || len(room.recorder.records) == 0 {
+		return
+	}
+
+	path := filepath.Join(room.recorder.dir, fmt.Sprintf("%s-%d.jsonl", room.uid, room.recorder.games))
+	if err := writeReplay(path, room.recorder.records); err != nil {
+		room.logInfo("Could not save replay: %s", err)
+		return
+	}
+	room.logInfo("Replay saved to %s", path)
+}
+
+func writeReplay(path string, records []ReplayRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+type Replay struct {
+	Records []ReplayRecord
+}
+
+func loadReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	replay := &Replay{}
+	decoder := json.NewDecoder(file)
+	for {
+		var record ReplayRecord
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w", path, len(replay.Records)+1, err)
+		}
+		replay.Records = append(replay.Records, record)
+	}
+
+	if len(replay.Records) == 0 || replay.Records[0].Kind != REPLAY_START {
+		return nil, fmt.Errorf("%s: replay has to start with %q record", path, REPLAY_START)
+	}
+	return replay, nil
+}
+
+// ReplayViewer steps through replay re-playing it with the same rules as the real game,
+// so every recorded result is verified
+type ReplayViewer struct {
+	replay   *Replay
+	room     *Room // Offline room, it is not stored in ROOMS_CONTAINER so nothing is sent
+	observer *Player
+	position int
+}
+
+func newReplayViewer(replay *Replay) (*ReplayViewer, error) {
+	start := replay.Records[0]
+
+	requested := CtosRules{}
+	if err := json.Unmarshal(start.Rules, &requested); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	rules, err := newRules(&requested)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	cfg := defaultConfig()
+	room := newOfflineRoom(cfg, rules, start.PrimaryName, start.SecondaryName)
+	if rules.Teams {
+		room.tertiary.name = start.TertiaryName
+		room.quaternary.name = start.QuaternaryName
+	}
+
+	viewer := &ReplayViewer{
+		replay:   replay,
+		room:     room,
+		observer: &Player{cfg: cfg, room: room, role: SPECTATOR, fullReveal: true},
+		position: 1,
+	}
+
+	// Placements always go right after start
+	for !viewer.done() && replay.Records[viewer.position].Kind == REPLAY_PLACE {
+		if _, err := viewer.step(); err != nil {
+			return nil, err
+		}
+	}
+	room.gamestate = PLAYING
+	room.revealRocks()
+	return viewer, nil
+}
+
+func (viewer *ReplayViewer) done() bool {
+	return viewer.position >= len(viewer.replay.Records)
+}
+
+// step applies next record and returns it, io.EOF is returned if replay is over
+func (viewer *ReplayViewer) step() (ReplayRecord, error) {
+	if viewer.done() {
+		return ReplayRecord{}, io.EOF
+	}
+
+	record := viewer.replay.Records[viewer.position]
+	viewer.position++
+
+	room := viewer.room
+	player := room.player(record.Role)
+	if player == nil {
+		return record, fmt.Errorf("record %d: invalid role %d", viewer.position, record.Role)
+	}
+
+	switch record.Kind {
+	case REPLAY_PLACE:
+		if record.Entity == nil {
+			return record, fmt.Errorf("record %d: entity is missing", viewer.position)
+		}
+		entity, err := newEntity(&room.rules, record.Entity.Type_, Vec2{x: record.Entity.Position.X, y: record.Entity.Position.Y}, record.Entity.Direction)
+		if err == nil {
+			err = player.addEntity(entity)
+		}
+		if err != nil {
+			return record, fmt.Errorf("record %d: %w", viewer.position, err)
+		}
+	case REPLAY_TURN:
+		// Shots don't switch turns during replay, weapons, salvos and mines make it differ from a single shot
+		room.turn = record.Role
+	case REPLAY_SHOT:
+		if record.Position == nil {
+			return record, fmt.Errorf("record %d: position is missing", viewer.position)
+		}
+		if room.turn != record.Role {
+			return record, fmt.Errorf("record %d: shot out of turn", viewer.position)
+		}
+		if record.Target != 0 {
+			if err := player.setTarget(record.Target); err != nil {
+				return record, fmt.Errorf("record %d: %w", viewer.position, err)
+			}
+		}
+		result := room.shoot(player, Vec2{x: record.Position.X, y: record.Position.Y})
+		if result != record.Result {
+			return record, fmt.Errorf("record %d: shot result %d differs from recorded %d", viewer.position, result, record.Result)
+		}
+	case REPLAY_WIN:
+		if !record.Forfeit && !player.enemy().isTotallyDead() {
+			return record, fmt.Errorf("record %d: winner has not destroyed the enemy fleet", viewer.position)
+		}
+		room.gamestate = OVER
+	default:
+		return record, fmt.Errorf("record %d: unexpected record kind %q", viewer.position, record.Kind)
+	}
+	return record, nil
+}
+
+// snapshot returns current state of replayed game with all ships revealed
+func (viewer *ReplayViewer) snapshot() StocStateSnapshot {
+	return viewer.observer.snapshot()
+}
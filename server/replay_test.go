@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testFleetCells() []Vec2 {
+	var cells []Vec2
+	for _, line := range []struct{ y, from, to int }{
+		{1, 1, 4},
+		{3, 1, 3}, {3, 5, 7},
+		{5, 1, 2}, {5, 4, 5}, {5, 7, 8},
+		{7, 1, 1}, {7, 3, 3}, {7, 5, 5}, {7, 7, 7},
+	} {
+		for x := line.from; x <= line.to; x++ {
+			cells = append(cells, Vec2{x: x, y: line.y})
+		}
+	}
+	return cells
+}
+
+func playTestGame(t *testing.T, cfg *Config) string {
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	room.fire(room.primary, Vec2{x: 10, y: 10})
+	room.fire(room.secondary, Vec2{x: 10, y: 10})
+	for _, point := range testFleetCells() {
+		room.fire(room.primary, point)
+	}
+
+	if !room.over() {
+		t.Fatalf("Game is not over after whole fleet was destroyed")
+	}
+	return filepath.Join(cfg.ReplayDir, room.uid+"-1.jsonl")
+}
+
+func TestReplay(t *testing.T) {
+	cfg := newTestConfig(t)
+	path := playTestGame(t, cfg)
+
+	replay, err := loadReplay(path)
+	if err != nil {
+		t.Fatalf("Could not load replay: %s", err)
+	}
+
+	viewer, err := newReplayViewer(replay)
+	if err != nil {
+		t.Fatalf("Could not start replay: %s", err)
+	}
+
+	shots := 0
+	for {
+		record, err := viewer.step()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Replay is inconsistent: %s", err)
+		}
+		if record.Kind == REPLAY_SHOT {
+			shots++
+		}
+	}
+
+	if shots != 2+len(testFleetCells()) {
+		t.Errorf("Unexpected shots count in replay: %d", shots)
+	}
+
+	snapshot := viewer.snapshot()
+	if snapshot.Gamestate_ != OVER || len(snapshot.Battlefields[0].Entities) != 10 {
+		t.Errorf("Replayed game has unexpected state: %+v", snapshot)
+	}
+}
+
+func TestReplayVerification(t *testing.T) {
+	cfg := newTestConfig(t)
+	replay, err := loadReplay(playTestGame(t, cfg))
+	if err != nil {
+		t.Fatalf("Could not load replay: %s", err)
+	}
+
+	for i := range replay.Records {
+		if replay.Records[i].Kind == REPLAY_SHOT && replay.Records[i].Result == SHOT_HIT {
+			replay.Records[i].Result = SHOT_MISS
+			break
+		}
+	}
+
+	viewer, err := newReplayViewer(replay)
+	if err != nil {
+		t.Fatalf("Could not start replay: %s", err)
+	}
+	for {
+		_, err := viewer.step()
+		if err == io.EOF {
+			t.Errorf("Tampered replay was not detected")
+			break
+		}
+		if err != nil {
+			break
+		}
+	}
+}
+
+func TestReplayInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.jsonl")
+	os.WriteFile(path, []byte(`{"kind": "shot"}`), 0o600)
+
+	if _, err := loadReplay(path); err == nil {
+		t.Errorf("Replay without start record was loaded")
+	}
+}
+
+func TestTeamReplay(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := newTestPlayingRoom(t, cfg, newTeamTestRules(false))
+	defer room.destroy()
+
+	// Chosen battlefield is not the first one afloat, so replay has to know it
+	if err := room.primary.setTarget(QUATERNARY); err != nil {
+		t.Fatalf("Could not choose target: %s", err)
+	}
+	sinkFleet(t, room, room.primary, room.quaternary)
+	sinkFleet(t, room, room.primary, room.secondary)
+	if !room.over() {
+		t.Fatalf("Game is not over after both fleets were destroyed")
+	}
+
+	replay, err := loadReplay(filepath.Join(cfg.ReplayDir, room.uid+"-1.jsonl"))
+	if err != nil {
+		t.Fatalf("Could not load replay: %s", err)
+	}
+	viewer, err := newReplayViewer(replay)
+	if err != nil {
+		t.Fatalf("Could not start replay: %s", err)
+	}
+	shots := 0
+	for {
+		record, err := viewer.step()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Replay is inconsistent: %s", err)
+		}
+		if record.Kind != REPLAY_SHOT {
+			continue
+		}
+		// Test fleets are the same, so only the order of sunk fleets tells shots went to chosen battlefield
+		if shots++; shots == len(testFleetCells()) && (!viewer.room.quaternary.isTotallyDead() || viewer.room.secondary.isTotallyDead()) {
+			t.Errorf("Shots were replayed at another battlefield")
+		}
+	}
+
+	if !viewer.room.over() || viewer.room.quaternary.name != "quaternary" {
+		t.Errorf("Team game was not replayed")
+	}
+}
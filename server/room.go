@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,24 +23,46 @@ const (
 
 type Room struct {
 	mtx              sync.Mutex
+	cfg              *Config
+	createdAt        time.Time
 	lastGamestateSet time.Time
 	gamestate        Gamestate
 	uid              string
+	rules            Rules
 	primary          *Player
 	secondary        *Player
+	tertiary         *Player // Team rooms only, see Rules.Teams
+	quaternary       *Player
+	spectators       []*Player
 	turn             PlayerRoleType
+	turnDeadline     time.Time       // Zero if shot clock is disabled or nobody is making a move
+	recorder         *ReplayRecorder // Nil if replays are disabled
+	public           bool            // Room is listed in lobby, otherwise it can be joined only by uid
+	listing          atomic.Pointer[StocRoomInfo]
+	passwordSalt     string // Room is not protected with password if hash is empty
+	passwordHash     string
+	quiet            bool // Nothing is logged, see newOfflineRoom
 }
 
-func createRoom(player *Player) *Room {
+func createRoom(player *Player, rules Rules) *Room {
 	room := Room{
+		cfg:              player.cfg,
+		createdAt:        time.Now(),
 		lastGamestateSet: time.Now(),
 		uid:              uuid.New().String(),
+		rules:            rules,
+		recorder:         newReplayRecorder(player.cfg.ReplayDir),
 		gamestate:        INITIAL,
 		turn:             SECONDARY, // Initial has to be SECONDARY so switchTurn() will start from PRIMARY
 	}
 
-	player.room = &room
+	if rules.Teams {
+		room.turn = QUATERNARY // Last one in turn order, see roles()
+	}
+
+	player.setRoom(&room)
 	player.role = PRIMARY
+	player.sessionToken = newSessionToken()
 	room.primary = player
 
 	ROOMS_CONTAINER.Store(room.uid, &room)
@@ -46,57 +71,120 @@ func createRoom(player *Player) *Room {
 	return &room
 }
 
-func (room *Room) addSecondary(player *Player) bool {
-	if room.secondary == nil {
-		player.room = room
-		player.role = SECONDARY
-		room.secondary = player
+// newOfflineRoom creates room in building stage which is not in ROOMS_CONTAINER, so nothing is sent from it
+// and nobody can join it. Nothing is logged either, as it's used to replay and simulate thousands of games
+func newOfflineRoom(cfg *Config, rules Rules, primaryName string, secondaryName string) *Room {
+	room := &Room{
+		cfg:       cfg,
+		rules:     rules,
+		gamestate: BUILDING,
+		turn:      SECONDARY,
+		quiet:     true,
+	}
+	room.primary = &Player{cfg: cfg, name: primaryName, room: room, role: PRIMARY}
+	room.secondary = &Player{cfg: cfg, name: secondaryName, room: room, role: SECONDARY}
+	for _, role := range room.roles()[2:] { // Teammates are named by caller
+		*room.slot(role) = &Player{cfg: cfg, room: room, role: role}
+	}
+	return room
+}
 
-		room.announce(JOIN_ROOM, StocJoinRoom{
-			PrimaryName:   room.primary.name,
-			SecondaryName: room.secondary.name,
-		})
+// addPlayer puts player to the first free slot of room, building stage starts once room is full
+func (room *Room) addPlayer(player *Player) bool {
+	for _, role := range room.roles() {
+		slot := room.slot(role)
+		if *slot != nil {
+			continue
+		}
 
-		room.logInfo("Joined as secondary player: %s", player.name)
+		player.setRoom(room)
+		player.role = role
+		player.sessionToken = newSessionToken()
+		*slot = player
 
-		room.startBuilding()
+		for _, thisPlayer := range room.players() {
+			thisPlayer.send(JOIN_ROOM, room.joinRoomEvent(thisPlayer.sessionToken))
+		}
+		room.announceToSpectators(JOIN_ROOM, room.joinRoomEvent(""), false)
+
+		room.logInfo("Joined as player %d: %s", role, player.name)
+		room.updateListing()
+
+		if room.full() {
+			room.startBuilding()
+		}
 
 		return true
 	}
 	return false
 }
 
-func (room *Room) announce(code EventCode, data any) {
-	if !room.valid() {
-		return
+func (room *Room) joinRoomEvent(sessionToken string) StocJoinRoom {
+	response := StocJoinRoom{
+		Rules:        room.rules.toStoc(),
+		SessionToken: sessionToken,
+		Players:      room.teamPlayers(),
 	}
 	if room.primary != nil {
-		room.primary.send(code, data)
+		response.PrimaryName = room.primary.name
 	}
 	if room.secondary != nil {
-		room.secondary.send(code, data)
+		response.SecondaryName = room.secondary.name
 	}
+	return response
+}
+
+// players returns all players currently occupying room slots
+func (room *Room) players() []*Player {
+	var players []*Player
+	for _, role := range room.roles() {
+		if player := room.player(role); player != nil {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+// announce sends event to everyone in room. Never announce anything revealing ships which are not sunk yet
+func (room *Room) announce(code EventCode, data any) {
+	if !room.valid() {
+		return
+	}
+	for _, player := range room.players() {
+		player.send(code, data)
+	}
+	room.announceToSpectators(code, data, false)
 }
 
 func (room *Room) logInfo(format string, args ...any) {
+	if room.quiet {
+		return
+	}
 	str := fmt.Sprintf("[%s]: %s\n", room.uid, format)
 	log.Printf(str, args...)
 }
 
-func (room *Room) isInitialTimeoutExceeded() bool {
-	return !room.building() && !room.playing() && time.Since(room.lastGamestateSet) >= MAX_INITIAL_TIMEOUT
+func (room *Room) isInitialTimeoutExceeded(now time.Time) bool {
+	return !room.building() && !room.playing() && !room.over() && now.Sub(room.lastGamestateSet) >= room.cfg.InitialTimeout
+}
+
+func (room *Room) isBuildingTimeoutExceeded(now time.Time) bool {
+	return room.building() && now.Sub(room.lastGamestateSet) >= room.cfg.BuildingTimeout
 }
 
-func (room *Room) isBuildingTimeoutExceeded() bool {
-	return room.building() && time.Since(room.lastGamestateSet) >= MAX_BUILDING_TIMEOUT
+func (room *Room) isGameplayTimeoutExceeded(now time.Time) bool {
+	return room.playing() && now.Sub(room.lastGamestateSet) >= room.cfg.GameplayTimeout
 }
 
-func (room *Room) isGameplayTimeoutExceeded() bool {
-	return room.playing() && time.Since(room.lastGamestateSet) >= MAX_GAMEPLAY_TIMEOUT
+func (room *Room) isOverTimeoutExceeded(now time.Time) bool {
+	return room.over() && now.Sub(room.lastGamestateSet) >= room.cfg.RevengeRequestTimeout
 }
 
-func (room *Room) isOverTimeoutExceeded() bool {
-	return room.playing() && time.Since(room.lastGamestateSet) >= MAX_REVENGE_REQUEST_TIMEOUT
+func (room *Room) isTimeoutExceeded(now time.Time) bool {
+	return room.isInitialTimeoutExceeded(now) ||
+		room.isGameplayTimeoutExceeded(now) ||
+		room.isBuildingTimeoutExceeded(now) ||
+		room.isOverTimeoutExceeded(now)
 }
 
 func (room *Room) building() bool {
@@ -112,13 +200,19 @@ func (room *Room) over() bool {
 }
 
 func (room *Room) startRevenge() bool {
-	if !room.over() || !room.primary.revengeRequested || !room.secondary.revengeRequested {
+	if !room.over() || !room.full() {
 		return false
 	}
+	for _, player := range room.players() {
+		if !player.revengeRequested {
+			return false
+		}
+	}
 
 	room.gamestate = INITIAL
-	room.primary.revengeRequested = false
-	room.secondary.revengeRequested = false
+	for _, player := range room.players() {
+		player.revengeRequested = false
+	}
 	room.startBuilding()
 
 	room.logInfo("Revenge!")
@@ -132,10 +226,19 @@ func (room *Room) startBuilding() bool {
 	}
 
 	room.setGamestate(BUILDING)
-	room.primary.clearEntities()
-	room.secondary.clearEntities()
+	for _, player := range room.players() {
+		player.clearEntities()
+		player.ready = false
+		player.target = 0
+		player.missedTurns = 0
+		player.turnsToSkip = 0
+		player.shots = 0
+		player.hits = 0
+		player.shipsSunk = 0
+	}
 
 	room.logInfo("Building stage has started")
+	room.prepareBots()
 
 	return true
 }
@@ -143,18 +246,21 @@ func (room *Room) startBuilding() bool {
 func (room *Room) setGamestate(state Gamestate) {
 	room.gamestate = state
 	room.lastGamestateSet = time.Now()
+	room.updateListing()
 	room.announce(SET_GAMESTATE, StocSetGamestate{
 		Gamestate_: state,
 	})
 }
 
 func (room *Room) canStartPlaying() bool {
-	if !room.building() || room.primary == nil || room.secondary == nil {
+	if !room.building() || !room.full() {
 		return false
 	}
 
-	if !room.primary.built() || !room.secondary.built() {
-		return false
+	for _, player := range room.players() {
+		if player.ownsBoard() && !player.ready {
+			return false
+		}
 	}
 
 	return true
@@ -168,22 +274,212 @@ func (room *Room) startPlaying() bool {
 	room.setGamestate(PLAYING)
 
 	room.logInfo("Let the greatest battle begin!")
+	room.revealRocks()
+	for _, player := range room.players() {
+		player.weapons = maps.Clone(room.rules.Weapons)
+	}
 
+	room.recordStart()
 	room.switchTurn()
 
 	return true
 }
 
 func (room *Room) switchTurn() {
-	if room.turn == PRIMARY {
-		room.turn = SECONDARY
-	} else {
-		room.turn = PRIMARY
+	roles := room.roles()
+	current := slices.Index(roles, room.turn)
+	next := room.turn // Current player keeps the turn if everyone else has to skip it
+	for i := 1; i < len(roles); i++ {
+		player := room.player(roles[(current+i)%len(roles)])
+		if player.turnsToSkip > 0 {
+			player.turnsToSkip--
+			room.logInfo("%s skips the turn", player.name)
+			continue
+		}
+		next = player.role
+		break
 	}
+	room.turn = next
 
 	room.announce(SET_TURN, StocSetTurn{
 		Role: room.turn,
 	})
+	room.record(ReplayRecord{
+		Kind: REPLAY_TURN,
+		Role: room.turn,
+	})
+
+	room.startTurnClock()
+	room.scheduleBotMove()
+}
+
+func (room *Room) player(role PlayerRoleType) *Player {
+	if slot := room.slot(role); slot != nil {
+		return *slot
+	}
+	return nil
+}
+
+// slot returns room field keeping player with the role
+func (room *Room) slot(role PlayerRoleType) **Player {
+	switch role {
+	case PRIMARY:
+		return &room.primary
+	case SECONDARY:
+		return &room.secondary
+	case TERTIARY:
+		return &room.tertiary
+	case QUATERNARY:
+		return &room.quaternary
+	}
+	return nil
+}
+
+// startTurnClock gives current player another TurnTimeout to make a move
+func (room *Room) startTurnClock() {
+	if room.cfg.TurnTimeout == 0 || !room.playing() {
+		return
+	}
+
+	room.turnDeadline = time.Now().Add(room.cfg.TurnTimeout)
+	room.announce(SET_TURN_DEADLINE, StocSetTurnDeadline{
+		Role:     room.turn,
+		Deadline: room.turnDeadline.UnixMilli(),
+		Timeout:  int(room.cfg.TurnTimeout.Seconds()),
+	})
+}
+
+func (room *Room) isTurnTimeoutExceeded(now time.Time) bool {
+	return room.playing() && !room.turnDeadline.IsZero() && !now.Before(room.turnDeadline)
+}
+
+// fire makes shooter shot at his enemy battlefield, then switches turn or finishes the game
+func (room *Room) fire(shooter *Player, point Vec2) ShotResult {
+	enemy := shooter.enemy()
+	result := room.shoot(shooter, point)
+
+	switch result {
+	case SHOT_MISS, SHOT_MINE:
+		room.switchTurn()
+	case SHOT_HIT, SHOT_SUNK:
+		if enemy.teamDefeated() {
+			room.finish(shooter, false)
+		} else {
+			room.startTurnClock()
+			room.scheduleBotMove()
+		}
+	}
+	return result
+}
+
+// shoot resolves a single shot and counts it in statistics, it's up to caller to switch turn
+func (room *Room) shoot(shooter *Player, point Vec2) ShotResult {
+	enemy := shooter.enemy()
+	result := enemy.shotAt(point)
+	if result != SHOT_INVALID {
+		shooter.shots++
+		if result == SHOT_HIT || result == SHOT_SUNK {
+			shooter.hits++
+		}
+		if result == SHOT_SUNK {
+			shooter.shipsSunk++
+		}
+
+		room.record(ReplayRecord{
+			Kind:     REPLAY_SHOT,
+			Role:     shooter.role,
+			Position: &StocPoint{X: point.x, Y: point.y},
+			Result:   result,
+			Target:   room.shotTarget(enemy),
+		})
+	}
+	if result == SHOT_MINE {
+		room.explodeMine(shooter, point)
+	}
+	if shooter.bot != nil {
+		var sunk *Entity
+		if result == SHOT_SUNK {
+			sunk = enemy.entityAt(point)
+		}
+		shooter.bot.observe(point, result, sunk)
+	}
+	return result
+}
+
+// shotTarget returns role of battlefield to record with shot, it's needed only in team rooms where it can be chosen
+func (room *Room) shotTarget(enemy *Player) PlayerRoleType {
+	if !room.rules.Teams {
+		return 0
+	}
+	return enemy.role
+}
+
+// turnTimeoutExceeded is called once current player has not made a move before turn deadline
+func (room *Room) turnTimeoutExceeded() {
+	player := room.player(room.turn)
+	player.missedTurns++
+
+	room.announce(TURN_TIMEOUT_EXCEEDED, StocTurnTimeoutExceeded{
+		Role:        player.role,
+		MissedTurns: player.missedTurns,
+	})
+	room.logInfo("%s has missed the turn (%d in a row)", player.name, player.missedTurns)
+
+	if room.cfg.MaxMissedTurns != 0 && player.missedTurns >= room.cfg.MaxMissedTurns {
+		room.finish(player.enemy(), true)
+		return
+	}
+
+	if room.cfg.TurnTimeoutAction == TURN_TIMEOUT_ACTION_SHOT && room.rules.Salvo {
+		room.fireSalvo(player, player.fillSalvo(nil))
+		return
+	}
+	if room.cfg.TurnTimeoutAction == TURN_TIMEOUT_ACTION_SHOT {
+		if point, ok := player.enemy().randomNotShotPoint(); ok {
+			room.fire(player, point)
+			return
+		}
+	}
+	room.switchTurn()
+}
+
+// finish announces the winner and ends the game
+func (room *Room) finish(winner *Player, forfeit bool) {
+	room.turnDeadline = time.Time{}
+	room.announce(PLAYER_WIN, StocPlayerWin{
+		Role:    winner.role,
+		Forfeit: forfeit,
+	})
+	room.setGamestate(OVER)
+
+	room.logInfo("%s has won the battle", winner.name)
+
+	room.record(ReplayRecord{
+		Kind:    REPLAY_WIN,
+		Role:    winner.role,
+		Forfeit: forfeit,
+	})
+	room.saveReplay()
+
+	for _, player := range room.players() {
+		if player.bot != nil { // Bots are always up for a revenge
+			player.revengeRequested = true
+			room.announce(REVENGE_REQUESTED, StocRevengeRequested{
+				Role: player.role,
+			})
+		}
+	}
+	if room.vsBot() || room.rules.Teams { // Ratings are kept for one-on-one games only
+		return
+	}
+
+	changes, err := STATS_STORE.recordGame(winner, winner.enemy())
+	if err != nil {
+		room.logInfo("Could not save statistics: %s", err)
+	}
+	room.announce(RATING_UPDATE, StocRatingUpdate{
+		Players: changes,
+	})
 }
 
 func (room *Room) destroy() {
@@ -196,8 +492,14 @@ func (room *Room) destroy() {
 
 	room.announce(ROOM_CLOSED, nil)
 
-	removeFromRoom(&room.primary)
-	removeFromRoom(&room.secondary)
+	for _, role := range room.roles() {
+		removeFromRoom(room.slot(role))
+	}
+	for _, spectator := range room.spectators {
+		spectator.stopDelayedEvents()
+		removeFromRoom(&spectator)
+	}
+	room.spectators = nil
 
 	ROOMS_CONTAINER.Delete(room.uid)
 	room.logInfo("Destroyed!")
@@ -211,7 +513,7 @@ func (room *Room) valid() bool {
 func removeFromRoom(field **Player) {
 	if (*field) != nil {
 		(*field).disconnect()
-		(*field).room = nil
+		(*field).setRoom(nil)
 		(*field) = nil
 	}
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/subtle"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// AttemptsLimiter counts failed attempts per remote address and blocks it for the rest of window once limit is reached
+type AttemptsLimiter struct {
+	mtx         sync.Mutex
+	maxAttempts int
+	window      time.Duration
+	clock       Clock
+	addresses   map[string]*failedAttempts
+}
+
+type failedAttempts struct {
+	count       int
+	windowStart time.Time
+}
+
+func newAttemptsLimiter(maxAttempts int, window time.Duration, clock Clock) *AttemptsLimiter {
+	return &AttemptsLimiter{
+		maxAttempts: maxAttempts,
+		window:      window,
+		clock:       clock,
+		addresses:   map[string]*failedAttempts{},
+	}
+}
+
+// remoteHost strips port from remote address, so reconnecting does not reset the limit
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// retryAfter returns how long address is blocked, zero if it is not
+func (limiter *AttemptsLimiter) retryAfter(remoteAddr string) time.Duration {
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+
+	now := limiter.clock.Now()
+	attempts, ok := limiter.addresses[remoteHost(remoteAddr)]
+	if !ok || attempts.count < limiter.maxAttempts || now.Sub(attempts.windowStart) >= limiter.window {
+		return 0
+	}
+	return attempts.windowStart.Add(limiter.window).Sub(now)
+}
+
+// fail counts failed attempt and returns how long address is blocked after it, zero if it is not
+func (limiter *AttemptsLimiter) fail(remoteAddr string) time.Duration {
+	limiter.mtx.Lock()
+	now := limiter.clock.Now()
+	host := remoteHost(remoteAddr)
+
+	attempts, ok := limiter.addresses[host]
+	if !ok || now.Sub(attempts.windowStart) >= limiter.window {
+		if !ok {
+			limiter.forgetExpired(now)
+		}
+		attempts = &failedAttempts{windowStart: now}
+		limiter.addresses[host] = attempts
+	}
+	attempts.count++
+	limiter.mtx.Unlock()
+
+	return limiter.retryAfter(remoteAddr)
+}
+
+// forgetExpired removes addresses which window is over. Limiter mutex has to be locked
+func (limiter *AttemptsLimiter) forgetExpired(now time.Time) {
+	for host, attempts := range limiter.addresses {
+		if now.Sub(attempts.windowStart) >= limiter.window {
+			delete(limiter.addresses, host)
+		}
+	}
+}
+
+// setPassword protects room with password, so it can't be joined or spectated by uid only. Room mutex has to be locked
+func (room *Room) setPassword(password string) {
+	room.passwordSalt = randomHex(PASSWORD_SALT_SIZE)
+	room.passwordHash = hashPassword(password, room.passwordSalt, PASSWORD_HASH_ITERATIONS)
+	room.updateListing()
+}
+
+func (room *Room) locked() bool {
+	return room.passwordHash != ""
+}
+
+// checkPassword reports if player may enter the room, sending INVALID_ROOM_PASSWORD if he may not.
+// Wrong guesses are limited per remote address. Room mutex has to be locked
+func (room *Room) checkPassword(player *Player, password string) bool {
+	if !room.locked() {
+		return true
+	}
+
+	retryAfter := ROOM_PASSWORD_LIMITER.retryAfter(player.remoteAddr)
+	if retryAfter == 0 {
+		hash := hashPassword(password, room.passwordSalt, PASSWORD_HASH_ITERATIONS)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(room.passwordHash)) == 1 {
+			return true
+		}
+		player.logInfo("Wrong password of room %s", room.uid)
+		retryAfter = ROOM_PASSWORD_LIMITER.fail(player.remoteAddr)
+	}
+
+	player.send(INVALID_ROOM_PASSWORD, StocInvalidRoomPassword{
+		RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+	})
+	return false
+}
+
+var ROOM_PASSWORD_LIMITER = newAttemptsLimiter(MAX_ROOM_PASSWORD_ATTEMPTS, ROOM_PASSWORD_ATTEMPTS_WINDOW, systemClock{})
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoomPassword(t *testing.T) {
+	cfg := newTestConfig(t)
+	limiter := ROOM_PASSWORD_LIMITER
+	defer func() { ROOM_PASSWORD_LIMITER = limiter }()
+	clock := &fakeClock{now: time.Now()}
+	ROOM_PASSWORD_LIMITER = newAttemptsLimiter(2, time.Minute, clock)
+
+	room := createRoom(newTestPlayer(cfg, "primary"), defaultRules())
+	defer room.destroy()
+	room.setPassword("secret")
+
+	guest := newTestPlayer(cfg, "127.0.0.1:1000")
+	if !room.checkPassword(guest, "secret") {
+		t.Errorf("Valid room password was rejected")
+	}
+	if room.checkPassword(guest, "wrong") || room.checkPassword(guest, "wrong") {
+		t.Errorf("Wrong room password was accepted")
+	}
+
+	reconnected := newTestPlayer(cfg, "127.0.0.1:2000")
+	if room.checkPassword(reconnected, "secret") {
+		t.Errorf("Password was checked after too many wrong guesses")
+	}
+	if ROOM_PASSWORD_LIMITER.retryAfter(newTestPlayer(cfg, "127.0.0.2:1000").remoteAddr) != 0 {
+		t.Errorf("Another address was blocked")
+	}
+
+	clock.advance(time.Minute)
+	if !room.checkPassword(reconnected, "secret") {
+		t.Errorf("Address was not unblocked after window is over")
+	}
+}
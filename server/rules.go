@@ -0,0 +1,396 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// Rule set limits
+const (
+	MIN_BATTLEFIELD_SIZE = 5
+	MAX_BATTLEFIELD_SIZE = 20
+	MAX_FLEET_TYPES      = 10
+	MAX_SHIPS_COUNT      = 30
+
+	MAX_SPECIAL_ENTITIES_COUNT = 10 // Of every special type
+)
+
+// MineEffect is what happens to the player who has shot at enemy mine
+type MineEffect string
+
+const (
+	MINE_SKIP_TURN   MineEffect = "skipTurn"   // Shooter misses his next turn
+	MINE_REVEAL_CELL MineEffect = "revealCell" // Random cell of shooter ship which is not hit yet is revealed to enemy
+)
+
+type ShipRule struct {
+	Size  Vec2   // Dimensions in horizontal
+	Cells []Vec2 // Shape cells in horizontal starting from 1, nil if ship is a rectangle of Size
+	Count int
+}
+
+// newShipRule builds ship rule from shape cells, they are shifted so the shape starts at 1:1.
+// Shapes filling their whole bounding box are stored as rectangles
+func newShipRule(cells []Vec2, count int) (ShipRule, error) {
+	ship := ShipRule{Count: count}
+	if len(cells) == 0 {
+		return ship, nil
+	}
+	if len(cells) > MAX_BATTLEFIELD_SIZE*MAX_BATTLEFIELD_SIZE {
+		return ship, fmt.Errorf("shape has more than %d cells", MAX_BATTLEFIELD_SIZE*MAX_BATTLEFIELD_SIZE)
+	}
+
+	start, end := cells[0], cells[0]
+	for _, cell := range cells {
+		start = Vec2{x: min(start.x, cell.x), y: min(start.y, cell.y)}
+		end = Vec2{x: max(end.x, cell.x), y: max(end.y, cell.y)}
+	}
+	// Differences are compared as unsigned, so coordinates far apart can't overflow into a small size
+	if uint(end.x-start.x) >= MAX_BATTLEFIELD_SIZE || uint(end.y-start.y) >= MAX_BATTLEFIELD_SIZE {
+		return ship, fmt.Errorf("shape is larger than %dx%d", MAX_BATTLEFIELD_SIZE, MAX_BATTLEFIELD_SIZE)
+	}
+	ship.Size = Vec2{x: end.x - start.x + 1, y: end.y - start.y + 1}
+	for _, cell := range cells {
+		ship.Cells = append(ship.Cells, Vec2{x: cell.x - start.x + 1, y: cell.y - start.y + 1})
+	}
+	if len(cells) == ship.Size.x*ship.Size.y && ship.validateShape() == nil {
+		ship.Cells = nil
+	}
+	return ship, nil
+}
+
+func (ship *ShipRule) cellsCount() int {
+	if ship.Cells != nil {
+		return len(ship.Cells)
+	}
+	return ship.Size.x * ship.Size.y
+}
+
+// validateShape checks that shape cells are unique, inside ship size and connected
+func (ship *ShipRule) validateShape() error {
+	if ship.Cells == nil {
+		return nil
+	}
+
+	cells := make(map[Vec2]bool, len(ship.Cells))
+	for _, cell := range ship.Cells {
+		if cell.x < 1 || cell.y < 1 || cell.x > ship.Size.x || cell.y > ship.Size.y {
+			return fmt.Errorf("shape cell %d:%d is out of size %dx%d", cell.x, cell.y, ship.Size.x, ship.Size.y)
+		}
+		if cells[cell] {
+			return fmt.Errorf("shape cell %d:%d is specified more than once", cell.x, cell.y)
+		}
+		cells[cell] = true
+	}
+
+	connected := []Vec2{ship.Cells[0]}
+	visited := map[Vec2]bool{ship.Cells[0]: true}
+	for i := 0; i < len(connected); i++ {
+		for _, direction := range []Vec2{{x: 1}, {x: -1}, {y: 1}, {y: -1}} {
+			next := Vec2{x: connected[i].x + direction.x, y: connected[i].y + direction.y}
+			if cells[next] && !visited[next] {
+				visited[next] = true
+				connected = append(connected, next)
+			}
+		}
+	}
+	if len(connected) != len(ship.Cells) {
+		return errors.New("shape cells are not connected")
+	}
+	return nil
+}
+
+// symmetric reports if ship shape is the same after rotation by 180°
+func (ship *ShipRule) symmetric() bool {
+	for _, cell := range ship.Cells {
+		if !slices.Contains(ship.Cells, Vec2{x: ship.Size.x - cell.x + 1, y: ship.Size.y - cell.y + 1}) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rules describes battlefield and fleet of a single room
+type Rules struct {
+	Width  int
+	Height int
+	Fleet  map[EntityType]ShipRule
+	Salvo  bool // Player fires a shot for every ship afloat per turn, see SALVO
+	// Ships may be placed next to each other. Cells around sunk ships are not revealed then,
+	// as it would tell whether there is another ship nearby
+	AllowTouching bool
+
+	Mines      int // Count of mines every player places along with ships
+	Rocks      int // Count of rocks every player places along with ships
+	MineEffect MineEffect
+
+	Weapons map[WeaponType]int // Uses of every weapon each player has per game, see USE_WEAPON
+
+	Teams        bool // Two teams of two players, see TERTIARY and QUATERNARY
+	SharedBoards bool // Teammates defend a single battlefield built by the team leader (PRIMARY or SECONDARY)
+}
+
+func defaultRules() Rules {
+	rules := Rules{
+		Width:  DEFAULT_BATTLEFIELD_WIDTH,
+		Height: DEFAULT_BATTLEFIELD_HEIGHT,
+		Fleet:  map[EntityType]ShipRule{},
+
+		MineEffect: MINE_SKIP_TURN,
+	}
+	for type_, size := range ENTITY_SIZE {
+		rules.Fleet[type_] = ShipRule{Size: size, Count: ENTITY_COUNT[type_]}
+	}
+	return rules
+}
+
+// newRules builds rule set requested by client and falls back to default rules if nothing was requested
+func newRules(requested *CtosRules) (Rules, error) {
+	if requested == nil {
+		return defaultRules(), nil
+	}
+
+	rules := Rules{
+		Width:  requested.Width,
+		Height: requested.Height,
+		Fleet:  map[EntityType]ShipRule{},
+		Salvo:  requested.Salvo,
+
+		AllowTouching: requested.AllowTouching,
+
+		Mines:      requested.Mines,
+		Rocks:      requested.Rocks,
+		MineEffect: requested.MineEffect,
+
+		Weapons: requested.Weapons,
+
+		Teams:        requested.Teams,
+		SharedBoards: requested.SharedBoards,
+	}
+	if rules.MineEffect == "" {
+		rules.MineEffect = MINE_SKIP_TURN
+	}
+	for _, ship := range requested.Fleet {
+		if _, ok := rules.Fleet[ship.Type_]; ok {
+			return rules, fmt.Errorf("entity type %d is specified more than once", ship.Type_)
+		}
+		if len(ship.Cells) != 0 {
+			cells := make([]Vec2, 0, len(ship.Cells))
+			for _, cell := range ship.Cells {
+				cells = append(cells, Vec2{x: cell.X, y: cell.Y})
+			}
+			shipRule, err := newShipRule(cells, ship.Count)
+			if err != nil {
+				return rules, fmt.Errorf("entity type %d has invalid shape: %w", ship.Type_, err)
+			}
+			rules.Fleet[ship.Type_] = shipRule
+			continue
+		}
+		rules.Fleet[ship.Type_] = ShipRule{
+			Size:  Vec2{x: ship.Size.X, y: ship.Size.Y},
+			Count: ship.Count,
+		}
+	}
+
+	return rules, rules.validate()
+}
+
+// newRoomRules builds rule set of a new room. Rules which pass validate may still be impossible to build
+// a battlefield by, so the fleet is placed at random once to reject them before anyone gets stuck building
+func newRoomRules(requested *CtosRules) (Rules, error) {
+	rules, err := newRules(requested)
+	if err != nil {
+		return rules, err
+	}
+	board := newOfflineRoom(nil, rules, "", "").primary
+	if err := board.placeRandomFleet(); err != nil {
+		return rules, fmt.Errorf("fleet can't be placed into %dx%d battlefield", rules.Width, rules.Height)
+	}
+	return rules, nil
+}
+
+func (rules *Rules) validate() error {
+	if rules.Width < MIN_BATTLEFIELD_SIZE || rules.Width > MAX_BATTLEFIELD_SIZE ||
+		rules.Height < MIN_BATTLEFIELD_SIZE || rules.Height > MAX_BATTLEFIELD_SIZE {
+		return fmt.Errorf("battlefield size %dx%d is out of %d..%d range", rules.Width, rules.Height, MIN_BATTLEFIELD_SIZE, MAX_BATTLEFIELD_SIZE)
+	}
+	if len(rules.Fleet) == 0 || len(rules.Fleet) > MAX_FLEET_TYPES {
+		return fmt.Errorf("fleet must contain 1..%d entity types", MAX_FLEET_TYPES)
+	}
+
+	// Every ship has to fit into the battlefield, together with its no-touch halo unless ships may touch each other
+	halo := 2
+	if rules.AllowTouching {
+		halo = 0
+	}
+	shipsCount := 0
+	requiredArea := 0
+	for type_, ship := range rules.Fleet {
+		if reservedEntityType(type_) {
+			return fmt.Errorf("entity type %d is reserved", type_)
+		}
+		fitsHorizontally := ship.Size.x <= rules.Width && ship.Size.y <= rules.Height
+		fitsVertically := ship.Size.y <= rules.Width && ship.Size.x <= rules.Height
+		if ship.Size.x < 1 || ship.Size.y < 1 || !(fitsHorizontally || fitsVertically) {
+			return fmt.Errorf("entity type %d has invalid size %dx%d", type_, ship.Size.x, ship.Size.y)
+		}
+		if err := ship.validateShape(); err != nil {
+			return fmt.Errorf("entity type %d has invalid shape: %w", type_, err)
+		}
+		if ship.Count < 0 {
+			return fmt.Errorf("entity type %d has negative count", type_)
+		}
+		shipsCount += ship.Count
+		requiredArea += ship.Count * (ship.Size.x + halo) * (ship.Size.y + halo)
+	}
+	if shipsCount == 0 || shipsCount > MAX_SHIPS_COUNT {
+		return fmt.Errorf("fleet must contain 1..%d ships", MAX_SHIPS_COUNT)
+	}
+	for type_, count := range map[EntityType]int{MINE: rules.Mines, ROCK: rules.Rocks} {
+		if count < 0 || count > MAX_SPECIAL_ENTITIES_COUNT {
+			return fmt.Errorf("entity type %d count is out of 0..%d range", type_, MAX_SPECIAL_ENTITIES_COUNT)
+		}
+		requiredArea += count * (1 + halo) * (1 + halo)
+	}
+	if rules.Mines != 0 && rules.MineEffect != MINE_SKIP_TURN && rules.MineEffect != MINE_REVEAL_CELL {
+		return fmt.Errorf("unknown mine effect: %q", rules.MineEffect)
+	}
+	if err := validateWeapons(rules.Weapons); err != nil {
+		return err
+	}
+	if rules.Salvo && len(rules.Weapons) != 0 {
+		return errors.New("weapons can't be used with salvo rules")
+	}
+	if rules.Salvo && rules.Teams {
+		return errors.New("salvo rules can't be used in team rooms")
+	}
+	if rules.SharedBoards && !rules.Teams {
+		return errors.New("shared battlefields can be used only in team rooms")
+	}
+	if requiredArea > (rules.Width+halo)*(rules.Height+halo) {
+		return fmt.Errorf("fleet does not fit into %dx%d battlefield", rules.Width, rules.Height)
+	}
+
+	return nil
+}
+
+// reservedEntityType reports if entity type is not a ship, so it can't be used in fleet
+func reservedEntityType(type_ EntityType) bool {
+	switch type_ {
+	case X_MARK, EMPTY_CELL, REVEALED_CELL, SONAR_CONTACT, SONAR_CLEAR, MINE, ROCK:
+		return true
+	}
+	return type_ <= 0
+}
+
+func (rules *Rules) inBounds(point Vec2) bool {
+	return point.x >= 1 && point.y >= 1 && point.x <= rules.Width && point.y <= rules.Height
+}
+
+func (rules *Rules) canPlaceEntityType(type_ EntityType) bool {
+	ship, ok := rules.entityRule(type_)
+	return ok && ship.Count > 0
+}
+
+// entityRule returns rule of ship or special entity type
+func (rules *Rules) entityRule(type_ EntityType) (ShipRule, bool) {
+	switch type_ {
+	case MINE:
+		return ShipRule{Size: Vec2{x: 1, y: 1}, Count: rules.Mines}, rules.Mines > 0
+	case ROCK:
+		return ShipRule{Size: Vec2{x: 1, y: 1}, Count: rules.Rocks}, rules.Rocks > 0
+	}
+	ship, ok := rules.Fleet[type_]
+	return ship, ok
+}
+
+// straightFleet reports if every ship is a straight line, so cells diagonal to a hit can't be occupied
+func (rules *Rules) straightFleet() bool {
+	for _, ship := range rules.Fleet {
+		if ship.Cells != nil || min(ship.Size.x, ship.Size.y) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// directions returns directions entity type can be placed in. Rotations by 180° are allowed only
+// for asymmetric shapes, otherwise the same placement could be described twice
+func (rules *Rules) directions(type_ EntityType) []DirectionType {
+	ship := rules.Fleet[type_]
+	if ship.symmetric() {
+		return []DirectionType{HORIZONTAL, VERTICAL}
+	}
+	return []DirectionType{HORIZONTAL, VERTICAL, ROTATED180, ROTATED270}
+}
+
+func (rules *Rules) maxPlaceableShipsCount() int {
+	count := 0
+	for _, ship := range rules.Fleet {
+		count += ship.Count
+	}
+	return count
+}
+
+// maxPlaceableEntitiesCount returns count of ships and special entities every player places
+func (rules *Rules) maxPlaceableEntitiesCount() int {
+	return rules.maxPlaceableShipsCount() + rules.Mines + rules.Rocks
+}
+
+// entityTypes returns fleet entity types in stable order
+func (rules *Rules) entityTypes() []EntityType {
+	types := make([]EntityType, 0, len(rules.Fleet))
+	for type_ := range rules.Fleet {
+		types = append(types, type_)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// placeableTypes returns fleet entity types followed by special ones allowed in the room
+func (rules *Rules) placeableTypes() []EntityType {
+	types := rules.entityTypes()
+	if rules.Mines > 0 {
+		types = append(types, MINE)
+	}
+	if rules.Rocks > 0 {
+		types = append(types, ROCK)
+	}
+	return types
+}
+
+func (rules *Rules) toStoc() StocRules {
+	result := StocRules{
+		Width:  rules.Width,
+		Height: rules.Height,
+		Salvo:  rules.Salvo,
+
+		AllowTouching: rules.AllowTouching,
+
+		Mines:      rules.Mines,
+		Rocks:      rules.Rocks,
+		MineEffect: rules.MineEffect,
+
+		Weapons: maps.Clone(rules.Weapons),
+
+		Teams:        rules.Teams,
+		SharedBoards: rules.SharedBoards,
+	}
+	for _, type_ := range rules.entityTypes() {
+		ship := rules.Fleet[type_]
+		entry := StocShipRule{
+			Type_: type_,
+			Count: ship.Count,
+		}
+		entry.Size.X = ship.Size.x
+		entry.Size.Y = ship.Size.y
+		for _, cell := range ship.Cells {
+			entry.Cells = append(entry.Cells, StocPoint{X: cell.x, Y: cell.y})
+		}
+		result.Fleet = append(result.Fleet, entry)
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDefaultRules(t *testing.T) {
+	rules, err := newRules(nil)
+	if err != nil {
+		t.Errorf("Default rules are invalid: %s", err)
+	}
+
+	if rules.Width != 10 || rules.Height != 10 || rules.maxPlaceableShipsCount() != 10 {
+		t.Errorf("Default rules have unexpected values: %+v", rules)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	requested := CtosRules{Width: 12, Height: 12}
+	requested.Fleet = make([]CtosShipRule, 1)
+	requested.Fleet[0].Type_ = FOURDECK
+	requested.Fleet[0].Size.X = 5
+	requested.Fleet[0].Size.Y = 1
+	requested.Fleet[0].Count = 2
+
+	rules, err := newRules(&requested)
+	if err != nil {
+		t.Errorf("Custom rules are valid but were rejected: %s", err)
+	}
+
+	if _, err := newEntity(&rules, FOURDECK, Vec2{x: 8, y: 12}, HORIZONTAL); err != nil {
+		t.Errorf("Entity is not out of boundaries but was not summoned: %s", err)
+	}
+	if _, err := newEntity(&rules, FOURDECK, Vec2{x: 9, y: 12}, HORIZONTAL); err == nil {
+		t.Errorf("Entity is out of boundaries but was summoned")
+	}
+	if _, err := newEntity(&rules, THREEDECK, Vec2{x: 1, y: 1}, HORIZONTAL); err == nil {
+		t.Errorf("Entity type is not in fleet but was summoned")
+	}
+
+	requested.Width = 30
+	if _, err := newRules(&requested); err == nil {
+		t.Errorf("Battlefield is too wide but rules were accepted")
+	}
+
+	requested.Width = 5
+	requested.Height = 5
+	requested.Fleet[0].Count = 20
+	if _, err := newRules(&requested); err == nil {
+		t.Errorf("Fleet does not fit battlefield but rules were accepted")
+	}
+}
+
+func TestUnplaceableRules(t *testing.T) {
+	// Fits by area, but every cell left is next to the big ship
+	requested := CtosRules{Width: 5, Height: 5}
+	requested.Fleet = make([]CtosShipRule, 2)
+	requested.Fleet[0].Type_ = FOURDECK
+	requested.Fleet[0].Size.X = 4
+	requested.Fleet[0].Size.Y = 4
+	requested.Fleet[0].Count = 1
+	requested.Fleet[1].Type_ = SINGLEDECK
+	requested.Fleet[1].Size.X = 1
+	requested.Fleet[1].Size.Y = 1
+	requested.Fleet[1].Count = 1
+
+	if _, err := newRules(&requested); err != nil {
+		t.Fatalf("Test rules are invalid: %s", err)
+	}
+	if _, err := newRoomRules(&requested); err == nil {
+		t.Errorf("Room was allowed with fleet which can't be placed")
+	}
+	if _, err := newRoomRules(nil); err != nil {
+		t.Errorf("Room was not allowed with default rules: %s", err)
+	}
+}
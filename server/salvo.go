@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+// salvoSize returns how many shots player fires per turn in salvo mode: one for every ship afloat,
+// but no more than there are enemy cells left to shoot at
+func (pl *Player) salvoSize() int {
+	ships := 0
+	for _, entity := range pl.entities {
+		if entity.ship() && !entity.destroyed() {
+			ships++
+		}
+	}
+	return min(ships, len(pl.enemy().notShotPoints()))
+}
+
+// fillSalvo completes salvo with random points of enemy battlefield which were never shot before
+func (pl *Player) fillSalvo(points []Vec2) []Vec2 {
+	var free []Vec2
+	for _, point := range pl.enemy().notShotPoints() {
+		if !slices.Contains(points, point) {
+			free = append(free, point)
+		}
+	}
+	rand.Shuffle(len(free), func(i, j int) { free[i], free[j] = free[j], free[i] })
+
+	for len(points) < pl.salvoSize() && len(free) != 0 {
+		points = append(points, free[0])
+		free = free[1:]
+	}
+	return points
+}
+
+// validateSalvo checks the whole salvo before any of its shots is fired
+func (room *Room) validateSalvo(shooter *Player, points []Vec2) error {
+	if size := shooter.salvoSize(); len(points) != size {
+		return fmt.Errorf("salvo has to contain %d shots, got %d", size, len(points))
+	}
+	enemy := shooter.enemy()
+	for i, point := range points {
+		if !room.rules.inBounds(point) {
+			return fmt.Errorf("shot at %+v is out of battlefield", point)
+		}
+		if enemy.isAlreadyShotAt(point) {
+			return fmt.Errorf("point %+v was already shot at", point)
+		}
+		if slices.Contains(points[:i], point) {
+			return fmt.Errorf("point %+v is shot at more than once", point)
+		}
+	}
+	return nil
+}
+
+// fireSalvo resolves all shots of validated salvo, announces their results and passes the turn
+func (room *Room) fireSalvo(shooter *Player, points []Vec2) {
+	enemy := shooter.enemy()
+	salvo := StocSalvo{
+		Role: shooter.role,
+	}
+	for _, point := range points {
+		salvo.Shots = append(salvo.Shots, StocSalvoShot{
+			X:      point.x,
+			Y:      point.y,
+			Result: room.shoot(shooter, point),
+		})
+	}
+	room.announce(SALVO, salvo)
+
+	if enemy.isTotallyDead() {
+		room.finish(shooter, false)
+	} else {
+		room.switchTurn()
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestSalvo(t *testing.T) {
+	cfg := newTestConfig(t)
+	rules := defaultRules()
+	rules.Salvo = true
+	room := createRoom(newTestPlayer(cfg, "primary"), rules)
+	defer room.destroy()
+	room.addPlayer(newTestPlayer(cfg, "secondary"))
+	placeTestFleet(t, room.primary)
+	placeTestFleet(t, room.secondary)
+	if !room.startPlaying() {
+		t.Fatalf("Could not start playing")
+	}
+	primary := room.primary
+
+	if size := primary.salvoSize(); size != 10 {
+		t.Fatalf("Unexpected salvo size: %d", size)
+	}
+	points := []Vec2{{x: 1, y: 1}, {x: 2, y: 1}, {x: 3, y: 1}, {x: 4, y: 1}} // Sinks four-deck ship
+	for y := 1; y <= 6; y++ {
+		points = append(points, Vec2{x: 10, y: y})
+	}
+	for _, invalid := range [][]Vec2{
+		points[:9],
+		append(points[:9:9], points[0]),
+		append(points[:9:9], Vec2{x: 11, y: 1}),
+	} {
+		if room.validateSalvo(primary, invalid) == nil {
+			t.Errorf("Invalid salvo was accepted: %+v", invalid)
+		}
+	}
+	if err := room.validateSalvo(primary, points); err != nil {
+		t.Fatalf("Valid salvo was rejected: %s", err)
+	}
+
+	room.fireSalvo(primary, points)
+	if primary.shots != 10 || primary.hits != 4 || primary.shipsSunk != 1 {
+		t.Errorf("Unexpected statistics after salvo: %d shots, %d hits, %d sunk", primary.shots, primary.hits, primary.shipsSunk)
+	}
+	if room.turn != SECONDARY {
+		t.Errorf("Turn was not switched after salvo")
+	}
+	if size := room.secondary.salvoSize(); size != 9 {
+		t.Errorf("Salvo size does not depend on ships afloat: %d", size)
+	}
+}
+
+func TestSalvoBots(t *testing.T) {
+	rules := defaultRules()
+	rules.Salvo = true
+	for i := 0; i < 10; i++ {
+		if _, err := playOfflineGame(newTestConfig(t), rules, BOT_HUNT_TARGET, BOT_PROBABILITY); err != nil {
+			t.Fatalf("Could not play salvo game: %s", err)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"flag"
 	"io"
 	"log"
 	"net"
@@ -12,11 +12,45 @@ import (
 )
 
 func main() {
-	listener, err := net.Listen(CONN_TYPE, fmt.Sprintf("%s:%d", CONN_HOST, CONN_PORT))
+	if len(os.Args) > 1 && os.Args[1] == "tournament" {
+		if err := runTournament(os.Args[2:], os.Stdout); err != nil && !errors.Is(err, flag.ErrHelp) {
+			log.Panicln(err)
+		}
+		return
+	}
+
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	STATS_STORE, err = newStatsStore(cfg.StatsFile)
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	ACCOUNTS_STORE, err = newAccountsStore(cfg.AccountsFile)
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	listener, err := net.Listen(CONN_TYPE, cfg.address())
 	if err != nil {
 		log.Panicln(err)
 	}
 	defer listener.Close()
+
+	reaper := newReaper(cfg.ReaperInterval, systemClock{})
+	reaper.start()
+	defer reaper.close()
+
+	MATCHMAKER = newMatchmaker(cfg.MatchmakingInterval, systemClock{})
+	MATCHMAKER.start()
+	defer MATCHMAKER.close()
+
 	log.Printf("Seabattle server v%s started!\n", SERVER_VERSION)
 
 	for {
@@ -25,12 +59,13 @@ func main() {
 			log.Panicln(err)
 		}
 
-		go handleConnection(conn)
+		go handleConnection(conn, cfg)
 	}
 }
 
-func handleConnection(conn net.Conn) {
-	player := Player{
+func handleConnection(conn net.Conn, cfg *Config) {
+	player := &Player{
+		cfg:           cfg,
 		conn:          conn,
 		connectedAt:   time.Now(),
 		remoteAddr:    conn.RemoteAddr().String(),
@@ -47,31 +82,70 @@ func handleConnection(conn net.Conn) {
 	decoder := json.NewDecoder(conn)
 
 	for {
-		if !handleRequest(&player, decoder) {
+		if !handleRequest(player, decoder) {
 			return
 		}
+		if player.resumedAs != nil {
+			player = player.resumedAs
+		}
+	}
+}
+
+// isHandshakeEvent reports if event can be sent by player who is not in any room
+func isHandshakeEvent(code EventCode) bool {
+	switch code {
+	case CREATE_ROOM, CREATE_AI_ROOM, JOIN_ROOM, RESUME_SESSION, SPECTATE_ROOM, REGISTER, LOGIN, FIND_MATCH:
+		return true
 	}
+	return isQueryEvent(code)
+}
+
+// isQueryEvent reports if event only requests some information and can be sent at any time
+func isQueryEvent(code EventCode) bool {
+	switch code {
+	case PLAYER_STATS, LEADERBOARD, LIST_ROOMS:
+		return true
+	}
+	return false
+}
+
+// isQueueEvent reports if event can be sent by player who is looking for a match
+func isQueueEvent(code EventCode) bool {
+	switch code {
+	case PING, DISCONNECT, CANCEL_MATCH:
+		return true
+	}
+	return isQueryEvent(code)
 }
 
 func handleRequest(player *Player, decoder *json.Decoder) bool {
 	lastEventTime := &player.lastEventTime
 	eventsCount := &player.eventsCount
 	rooms := &ROOMS_CONTAINER
-	timeout := MAX_PING_TIMEOUT
-	if !player.isInRoom() { // No room = no handshake
-		timeout = MAX_HANDSHAKE_TIMEOUT
+	conn := player.connection()
+	if conn == nil { // Connection was closed by room destroyed from another goroutine
+		return false
 	}
 
-	player.conn.SetReadDeadline(time.Now().Add(timeout))
+	cfg := player.cfg
+	timeout := cfg.PingTimeout
+	player.mtx.Lock() // Matchmaker puts player to room from its own goroutine
+	handshaked := player.currentRoom() != nil || player.queued()
+	player.mtx.Unlock()
+	if !handshaked { // No room = no handshake
+		timeout = cfg.HandshakeTimeout
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
 
-	if time.Since(*lastEventTime) <= MIN_EVENTS_INTERVAL {
+	if time.Since(*lastEventTime) <= cfg.MinEventsInterval {
 		*eventsCount++
 	} else {
 		*eventsCount = 0
 	}
 	*lastEventTime = time.Now()
 
-	if *eventsCount >= MAX_EVENTS_COUNT {
+	if *eventsCount >= cfg.MaxEventsCount {
 		// Antiflood
 		// TODO: send notification
 		return false
@@ -88,12 +162,7 @@ func handleRequest(player *Player, decoder *json.Decoder) bool {
 		}
 
 		if errors.Is(err, os.ErrDeadlineExceeded) {
-			player.logInfo("Handshake/ping timeout exceeded: %s. Initial handshaked: %t", timeout.String(), player.room != nil)
-			return false
-		}
-
-		if !player.isInRoom() && (err != nil || (event.Code != CREATE_ROOM && event.Code != JOIN_ROOM)) {
-			player.logInfo("Incorrect initial handshake event")
+			player.logInfo("Handshake/ping timeout exceeded: %s. Initial handshaked: %t", timeout.String(), handshaked)
 			return false
 		}
 
@@ -105,16 +174,20 @@ func handleRequest(player *Player, decoder *json.Decoder) bool {
 
 	player.mtx.Lock()
 	defer player.mtx.Unlock()
-	if player.isInRoom() {
-		player.room.mtx.Lock()
-		defer player.room.mtx.Unlock()
+	if room := player.currentRoom(); room != nil {
+		room.mtx.Lock()
+		defer room.mtx.Unlock()
+		if player.room != room || !room.valid() {
+			return false // Room was destroyed while we were waiting for its mutex
+		}
+	}
+
+	if !player.isInRoom() && !isHandshakeEvent(event.Code) && !(player.queued() && isQueueEvent(event.Code)) {
+		player.logInfo("Incorrect initial handshake event")
+		return false
 	}
 
-	if player.isInRoom() &&
-		(player.room.isInitialTimeoutExceeded() ||
-			player.room.isGameplayTimeoutExceeded() ||
-			player.room.isBuildingTimeoutExceeded() ||
-			player.room.isOverTimeoutExceeded()) {
+	if player.isInRoom() && player.room.isTimeoutExceeded(time.Now()) {
 		player.announceToRoom(GAMEPLAY_TIMEOUT_EXCEEDED, nil)
 		return false // break connection to let rooms and other room participants to destroy
 	}
@@ -123,19 +196,58 @@ func handleRequest(player *Player, decoder *json.Decoder) bool {
 		player.send(PING, nil)
 		return true
 	} else if event.Code == DISCONNECT {
+		player.quit = true
 		return false
 	}
 
+	if player.queued() && !isQueueEvent(event.Code) {
+		player.unknownError("you are looking for a match, cancel it first")
+		return true
+	}
+
+	if player.spectating() && event.Code != REQUEST_STATE && !isQueryEvent(event.Code) {
+		player.unknownError("spectators can only request state")
+		return true
+	}
+
 	var data interface{}
 	switch event.Code {
 	case CREATE_ROOM:
 		data = new(CtosCreateRoom)
+	case CREATE_AI_ROOM:
+		data = new(CtosCreateAiRoom)
 	case JOIN_ROOM:
 		data = new(CtosJoinRoom)
+	case RESUME_SESSION:
+		data = new(CtosResumeSession)
+	case SPECTATE_ROOM:
+		data = new(CtosSpectateRoom)
+	case FIND_MATCH:
+		data = new(CtosFindMatch)
+	case REGISTER:
+		data = new(CtosRegister)
+	case LOGIN:
+		data = new(CtosLogin)
+	case PLAYER_STATS:
+		data = new(CtosPlayerStats)
+	case LEADERBOARD:
+		data = new(CtosLeaderboard)
+	case LIST_ROOMS:
+		data = new(CtosListRooms)
 	case READY_TO_PLAY:
 		data = new(CtosReadyToPlay)
+	case PLACE_ENTITY:
+		data = new(CtosPlaceEntity)
+	case REMOVE_ENTITY:
+		data = new(CtosRemoveEntity)
+	case MOVE_ENTITY:
+		data = new(CtosMoveEntity)
 	case SHOT_AT:
 		data = new(CtosShotAt)
+	case SALVO:
+		data = new(CtosSalvo)
+	case USE_WEAPON:
+		data = new(CtosUseWeapon)
 	}
 
 	if data != nil {
@@ -157,15 +269,80 @@ func handleRequest(player *Player, decoder *json.Decoder) bool {
 			player.send(INVALID_CLIENT_VERSION, nil)
 			return false
 		}
-		if !isValidNickname(data.Nickname) {
-			player.send(INVALID_NICKNAME, nil)
+		if !player.useNickname(data.Nickname) {
+			return true
+		}
+		rules, err := newRoomRules(data.Rules)
+		if err != nil {
+			player.send(INVALID_RULES, StocInvalidRules{
+				Error: err.Error(),
+			})
+			return true
+		}
+		if len(data.Password) > MAX_PASSWORD_LEN {
+			player.send(INVALID_PASSWORD, nil)
 			return true
 		}
-		player.name = data.Nickname
 
+		room := createRoom(player, rules)
+		room.mtx.Lock()
+		if data.Password != "" {
+			room.setPassword(data.Password)
+		}
+		if data.Public {
+			room.publish()
+		}
+		room.mtx.Unlock()
 		player.send(CREATE_ROOM, StocCreateRoom{
-			RoomUid: createRoom(player).uid,
+			RoomUid:      room.uid,
+			SessionToken: player.sessionToken,
+		})
+	case CREATE_AI_ROOM:
+		if player.isInRoom() {
+			player.unknownError("you are already in room %s", player.room.uid)
+			return true
+		}
+		data := data.(*CtosCreateAiRoom)
+		if data.Version != CLIENT_VERSION_REQUIRED {
+			player.send(INVALID_CLIENT_VERSION, nil)
+			return false
+		}
+		if !player.useNickname(data.Nickname) {
+			return true
+		}
+		rules, err := newRoomRules(data.Rules)
+		if err != nil {
+			player.send(INVALID_RULES, StocInvalidRules{
+				Error: err.Error(),
+			})
+			return true
+		}
+		if rules.Teams {
+			player.send(INVALID_RULES, StocInvalidRules{
+				Error: "team rooms can't be played against bot",
+			})
+			return true
+		}
+		bot, err := newBotPlayer(cfg, data.Difficulty)
+		if err != nil {
+			player.unknownError("%s", err)
+			return true
+		}
+		if err := bot.checkFleetPlacement(rules); err != nil {
+			player.send(INVALID_RULES, StocInvalidRules{
+				Error: "bot can't play by these rules: " + err.Error(),
+			})
+			return true
+		}
+
+		room := createRoom(player, rules)
+		player.send(CREATE_AI_ROOM, StocCreateRoom{
+			RoomUid:      room.uid,
+			SessionToken: player.sessionToken,
 		})
+		room.mtx.Lock()
+		room.addPlayer(bot)
+		room.mtx.Unlock()
 	case JOIN_ROOM:
 		if player.isInRoom() {
 			player.unknownError("you are already in room %s", player.room.uid)
@@ -177,112 +354,272 @@ func handleRequest(player *Player, decoder *json.Decoder) bool {
 			player.send(INVALID_CLIENT_VERSION, nil)
 			return false
 		}
-		if !isValidNickname(data.Nickname) {
-			player.send(INVALID_NICKNAME, nil)
+		if !player.useNickname(data.Nickname) {
 			return true
 		}
-		player.name = data.Nickname
 
-		if room, ok := rooms.Load(data.RoomUid); ok {
-			if room := room.(*Room); !room.addSecondary(player) {
-				player.send(ROOM_IS_FULL, nil)
-				return true
-			}
-		} else {
+		room, ok := rooms.Load(data.RoomUid)
+		if !ok {
 			player.send(INVALID_ROOM_UID, nil)
 			return true
 		}
-	case READY_TO_PLAY:
-		if !player.room.building() {
-			player.unknownError("not in building stage")
+		room_ := room.(*Room)
+		room_.mtx.Lock()
+		defer room_.mtx.Unlock()
+		if !room_.valid() {
+			player.send(INVALID_ROOM_UID, nil)
+			return true
+		}
+		if !room_.checkPassword(player, data.Password) {
 			return true
 		}
-		if player.built() {
-			player.unknownError("you've already built your battlefield")
+		if !room_.addPlayer(player) {
+			player.send(ROOM_IS_FULL, nil)
+			return true
+		}
+	case SPECTATE_ROOM:
+		if player.isInRoom() {
+			player.unknownError("you are already in room %s", player.room.uid)
 			return true
 		}
-		data := data.(*CtosReadyToPlay)
-		var lastError error
-		for _, entity := range data.Entities {
-			addingEntity, err := newEntity(
-				entity.Type_,
-				Vec2{x: entity.Position.X, y: entity.Position.Y},
-				entity.Direction,
-			)
 
-			if err != nil {
-				// Incorrect entity position/direction, probably a hack attempt
-				lastError = err
-				break
-			}
+		data := data.(*CtosSpectateRoom)
+		if data.Version != CLIENT_VERSION_REQUIRED {
+			player.send(INVALID_CLIENT_VERSION, nil)
+			return false
+		}
+		if !player.useNickname(data.Nickname) {
+			return true
+		}
+		if data.FullReveal && cfg.SpectatorRevealDelay == 0 {
+			player.unknownError("full reveal spectating is disabled")
+			return true
+		}
 
-			if err := player.addEntity(addingEntity); err != nil {
-				// Invalid entity type/entities count limit exceeded/intersecting entities were found, probably a hack attempt
-				lastError = err
-				break
-			}
+		room, ok := rooms.Load(data.RoomUid)
+		if !ok {
+			player.send(INVALID_ROOM_UID, nil)
+			return true
+		}
+		room_ := room.(*Room)
+		room_.mtx.Lock()
+		if !room_.checkPassword(player, data.Password) {
+			room_.mtx.Unlock()
+			return true
+		}
+		added := room_.valid() && room_.addSpectator(player, data.FullReveal)
+		room_.mtx.Unlock()
+		if !added {
+			player.send(ROOM_IS_FULL, nil)
+			return true
+		}
+	case RESUME_SESSION:
+		if player.isInRoom() {
+			player.unknownError("you are already in room %s", player.room.uid)
+			return true
 		}
 
-		if lastError != nil {
-			player.securityError(lastError.Error())
-			player.clearEntities()
-		} else if player.built() {
-			player.announceToRoom(READY_TO_PLAY, StocPlayerReadyToPlay{
-				Role: player.role,
-			})
+		data := data.(*CtosResumeSession)
+		if data.Version != CLIENT_VERSION_REQUIRED {
+			player.send(INVALID_CLIENT_VERSION, nil)
+			return false
+		}
 
-			// Let's sync player battlefield with the server data
+		room, ok := rooms.Load(data.RoomUid)
+		if !ok {
+			player.send(INVALID_ROOM_UID, nil)
+			return true
+		}
+		slot := room.(*Room).resume(player, data.SessionToken)
+		if slot == nil {
+			player.send(INVALID_SESSION, nil)
+			return true
+		}
+		player.resumedAs = slot
+	case FIND_MATCH:
+		if player.isInRoom() {
+			player.unknownError("you are already in room %s", player.room.uid)
+			return true
+		}
 
-			{
-				sendEvent := StocClearBattlefield{
-					Role: player.role,
-				}
-				sendEvent.Start.X = 1
-				sendEvent.Start.Y = 1
-				sendEvent.End.X = 10
-				sendEvent.End.Y = 10
+		data := data.(*CtosFindMatch)
+		if data.Version != CLIENT_VERSION_REQUIRED {
+			player.send(INVALID_CLIENT_VERSION, nil)
+			return false
+		}
+		if !player.useNickname(data.Nickname) {
+			return true
+		}
 
-				player.send(CLEAR_BATTLEFIELD, sendEvent)
-			}
+		player.logInfo("%s is looking for a match", player.name)
+		MATCHMAKER.enqueue(player)
+	case CANCEL_MATCH:
+		if !MATCHMAKER.dequeue(player) {
+			player.unknownError("you are not looking for a match")
+			return true
+		}
+		player.send(CANCEL_MATCH, nil)
+	case REGISTER:
+		if player.isInRoom() || player.authenticated {
+			player.unknownError("you can't register now")
+			return true
+		}
 
-			for _, entity := range player.entities {
-				event := StocAddEntity{
-					Role: player.role,
-				}
-				event.Entity.Type_ = entity.type_
-				event.Entity.Position.X = entity.position.x
-				event.Entity.Position.Y = entity.position.y
-				event.Entity.Direction = entity.direction
-				player.send(ADD_ENTITY, event)
-			}
+		data := data.(*CtosRegister)
+		player.register(data.Nickname, data.Password)
+	case LOGIN:
+		if player.isInRoom() || player.authenticated {
+			player.unknownError("you can't log in now")
+			return true
+		}
 
-			player.room.startPlaying()
-		} else {
-			player.unknownError("not enough entities were placed")
-			player.clearEntities()
+		data := data.(*CtosLogin)
+		player.logIn(data.Nickname, data.Password, data.Token)
+	case READY_TO_PLAY:
+		if !player.canEditFleet() {
+			return true
+		}
+		player.readyToPlay(data.(*CtosReadyToPlay))
+	case AUTO_PLACE:
+		if !player.canEditFleet() {
+			return true
+		}
+		player.autoPlace()
+	case CANCEL_READY:
+		if !player.canCancelReady() {
+			return true
+		}
+		player.cancelReady()
+	case PLACE_ENTITY:
+		if !player.canEditFleet() {
+			return true
+		}
+		data := data.(*CtosPlaceEntity)
+		player.placeEntity(data.Type_, Vec2{x: data.Position.X, y: data.Position.Y}, data.Direction)
+	case REMOVE_ENTITY:
+		if !player.canEditFleet() {
+			return true
+		}
+		data := data.(*CtosRemoveEntity)
+		player.removeEntityAt(Vec2{x: data.Position.X, y: data.Position.Y})
+	case MOVE_ENTITY:
+		if !player.canEditFleet() {
+			return true
 		}
+		data := data.(*CtosMoveEntity)
+		player.moveEntity(
+			Vec2{x: data.From.X, y: data.From.Y},
+			Vec2{x: data.Position.X, y: data.Position.Y},
+			data.Direction,
+		)
 	case SHOT_AT:
 		if !player.room.playing() {
 			player.unknownError("not in playing stage")
 			return true
 		}
+		if player.rules().Salvo {
+			player.unknownError("salvo rules are used in this room, send SALVO instead")
+			return true
+		}
 		if !player.canMakeMove() {
 			player.unknownError("not your turn")
 			return true
 		}
 
 		data := data.(*CtosShotAt)
+		if data.Target != 0 {
+			if err := player.setTarget(data.Target); err != nil {
+				player.unknownError("%s", err)
+				return true
+			}
+		}
 
-		if player.enemy().shotAt(Vec2{x: data.X, y: data.Y}) {
-			player.room.switchTurn()
+		if player.room.fire(player, Vec2{x: data.X, y: data.Y}) != SHOT_INVALID {
+			player.missedTurns = 0
+		}
+	case SALVO:
+		if !player.room.playing() {
+			player.unknownError("not in playing stage")
+			return true
+		}
+		if !player.rules().Salvo {
+			player.unknownError("salvo rules are not used in this room, send SHOT_AT instead")
+			return true
+		}
+		if !player.canMakeMove() {
+			player.unknownError("not your turn")
+			return true
 		}
 
-		if player.enemy().isTotallyDead() {
-			player.room.announce(PLAYER_WIN, StocPlayerWin{
-				Role: player.role,
-			})
-			player.room.setGamestate(OVER)
+		data := data.(*CtosSalvo)
+		points := make([]Vec2, 0, len(data.Shots))
+		for _, shot := range data.Shots {
+			points = append(points, Vec2{x: shot.X, y: shot.Y})
+		}
+		if err := player.room.validateSalvo(player, points); err != nil {
+			player.unknownError("%s", err)
+			return true
+		}
+
+		player.room.fireSalvo(player, points)
+		player.missedTurns = 0
+	case USE_WEAPON:
+		if !player.room.playing() {
+			player.unknownError("not in playing stage")
+			return true
+		}
+		if !player.canMakeMove() {
+			player.unknownError("not your turn")
+			return true
+		}
+
+		data := data.(*CtosUseWeapon)
+		if data.Target != 0 {
+			if err := player.setTarget(data.Target); err != nil {
+				player.unknownError("%s", err)
+				return true
+			}
+		}
+		if err := player.room.useWeapon(player, data.Weapon, Vec2{x: data.X, y: data.Y}, data.Direction); err != nil {
+			player.unknownError("%s", err)
+			return true
+		}
+		player.missedTurns = 0
+	case REQUEST_STATE:
+		player.sendSnapshot()
+	case PLAYER_STATS:
+		data := data.(*CtosPlayerStats)
+		stats, _ := STATS_STORE.get(data.Nickname)
+		player.send(PLAYER_STATS, stats.toStoc())
+	case LEADERBOARD:
+		data := data.(*CtosLeaderboard)
+		if data.Limit < 1 || data.Limit > MAX_LEADERBOARD_SIZE {
+			data.Limit = MAX_LEADERBOARD_SIZE
+		}
+
+		response := StocLeaderboard{
+			Players: []StocPlayerStats{},
+		}
+		for _, stats := range STATS_STORE.top(data.Limit) {
+			response.Players = append(response.Players, stats.toStoc())
+		}
+		player.send(LEADERBOARD, response)
+	case LIST_ROOMS:
+		data := data.(*CtosListRooms)
+		if data.PageSize < 1 || data.PageSize > MAX_ROOMS_PAGE_SIZE {
+			data.PageSize = MAX_ROOMS_PAGE_SIZE
 		}
+		if data.Page < 0 {
+			data.Page = 0
+		}
+
+		rooms, total := listRooms(data.Page, data.PageSize)
+		player.send(LIST_ROOMS, StocListRooms{
+			Rooms:      rooms,
+			Page:       data.Page,
+			PagesCount: (total + data.PageSize - 1) / data.PageSize,
+			Total:      total,
+		})
 	case REVENGE_REQUESTED:
 		if !player.room.over() {
 			player.unknownError("not in over stage")
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newSessionToken() string {
+	return uuid.New().String()
+}
+
+// canKeepSlot reports if room has to keep player slot for a while after his connection was lost
+func (pl *Player) canKeepSlot() bool {
+	return pl.isInRoom() && !pl.spectating() && !pl.quit && pl.cfg.ReconnectGracePeriod != 0 &&
+		(pl.room.building() || pl.room.playing())
+}
+
+func (pl *Player) connectionLost() bool {
+	return !pl.connectionLostAt.IsZero()
+}
+
+// lostPlayer returns player which grace period is over, if any
+func (room *Room) lostPlayer(now time.Time) *Player {
+	for _, player := range room.players() {
+		if player.connectionLost() && now.Sub(player.connectionLostAt) >= room.cfg.ReconnectGracePeriod {
+			return player
+		}
+	}
+	return nil
+}
+
+// resume attaches connection of player to the slot he has lost and returns the player occupying this slot
+func (room *Room) resume(player *Player, token string) *Player {
+	room.mtx.Lock()
+	defer room.mtx.Unlock()
+
+	if !room.valid() {
+		return nil
+	}
+
+	for _, slot := range room.players() {
+		if !slot.connectionLost() || slot.sessionToken != token {
+			continue
+		}
+
+		slot.takeConnection(player)
+		slot.remoteAddr = player.remoteAddr
+		slot.lastEventTime = player.lastEventTime
+		slot.eventsCount = 0
+		slot.connectionLostAt = time.Time{}
+
+		room.logInfo("%s has resumed the session from %s", slot.name, slot.remoteAddr)
+		room.announce(PLAYER_RECONNECTED, StocPlayerReconnected{
+			Role: slot.role,
+		})
+		slot.syncState()
+		slot.send(STATE_SNAPSHOT, slot.snapshot())
+		return slot
+	}
+	return nil
+}
+
+// syncState replays the whole room state to the player as if he was there from the beginning
+func (pl *Player) syncState() {
+	room := pl.room
+	rules := pl.rules()
+
+	pl.send(JOIN_ROOM, room.joinRoomEvent(""))
+	pl.send(SET_GAMESTATE, StocSetGamestate{
+		Gamestate_: room.gamestate,
+	})
+
+	for _, player := range room.players() {
+		sendEvent := StocClearBattlefield{
+			Role: player.role,
+		}
+		sendEvent.Start.X = 1
+		sendEvent.Start.Y = 1
+		sendEvent.End.X = rules.Width
+		sendEvent.End.Y = rules.Height
+		pl.send(CLEAR_BATTLEFIELD, sendEvent)
+	}
+
+	// Own team battlefields: ships and everything enemy has shot at
+	for _, board := range room.boards(teamOf(pl.role)) {
+		for _, entity := range board.entities {
+			pl.send(ADD_ENTITY, newStocAddEntity(board.role, entity.type_, entity.position, entity.direction))
+		}
+		for _, point := range board.shotPoints {
+			pl.send(ADD_ENTITY, newStocAddEntity(board.role, board.shotCellType(point), point, HORIZONTAL))
+		}
+		for _, point := range board.revealedPoints {
+			pl.send(ADD_ENTITY, newStocAddEntity(board.role, REVEALED_CELL, point, HORIZONTAL))
+		}
+	}
+
+	// Enemy battlefields: only what was revealed by shots
+	for _, enemy := range room.boards(opposingTeam(pl.role)) {
+		for _, point := range enemy.shotPoints {
+			if entity := enemy.entityAt(point); entity != nil && entity.destroyed() {
+				continue // Whole entity is sent below
+			}
+			pl.send(ADD_ENTITY, newStocAddEntity(enemy.role, enemy.shotCellType(point), point, HORIZONTAL))
+		}
+		for _, point := range enemy.revealedPoints {
+			pl.send(ADD_ENTITY, newStocAddEntity(enemy.role, REVEALED_CELL, point, HORIZONTAL))
+		}
+		for _, entity := range enemy.entities {
+			if entity.destroyed() {
+				pl.send(ADD_ENTITY, newStocAddEntity(enemy.role, entity.type_, entity.position, entity.direction))
+			}
+		}
+	}
+
+	if room.building() {
+		for _, player := range room.players() {
+			if player.ready {
+				pl.send(READY_TO_PLAY, StocPlayerReadyToPlay{
+					Role: player.role,
+				})
+			}
+		}
+	}
+
+	if room.playing() {
+		pl.send(SET_TURN, StocSetTurn{
+			Role: room.turn,
+		})
+		if !room.turnDeadline.IsZero() {
+			pl.send(SET_TURN_DEADLINE, StocSetTurnDeadline{
+				Role:     room.turn,
+				Deadline: room.turnDeadline.UnixMilli(),
+				Timeout:  int(room.cfg.TurnTimeout.Seconds()),
+			})
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResumeSession(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+	primary := room.primary
+
+	primary.destroy()
+	if !room.valid() || !primary.connectionLost() {
+		t.Fatalf("Slot was not kept after connection loss")
+	}
+
+	if slot := room.resume(newTestPlayer(cfg, "intruder"), "invalid token"); slot != nil {
+		t.Errorf("Session was resumed with invalid token")
+	}
+
+	if slot := room.resume(newTestPlayer(cfg, "primary"), primary.sessionToken); slot != primary {
+		t.Errorf("Session was not resumed with valid token")
+	}
+	if primary.connectionLost() || room.primary != primary {
+		t.Errorf("Resumed player does not occupy his slot")
+	}
+}
+
+func TestResumeGracePeriod(t *testing.T) {
+	cfg := newTestConfig(t)
+	clock := &fakeClock{now: time.Now()}
+	reaper := newReaper(cfg.ReaperInterval, clock)
+
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+	token := room.secondary.sessionToken
+
+	room.secondary.destroy()
+
+	clock.advance(cfg.ReconnectGracePeriod + time.Second)
+	if reaper.sweep() != 1 || room.valid() {
+		t.Errorf("Room was not destroyed after reconnection grace period")
+	}
+
+	if slot := room.resume(newTestPlayer(cfg, "secondary"), token); slot != nil {
+		t.Errorf("Session was resumed after room was destroyed")
+	}
+}
+
+func TestQuitDoesNotKeepSlot(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	room.primary.quit = true
+	room.primary.destroy()
+	if room.valid() {
+		t.Errorf("Room was not destroyed after player has quit")
+	}
+}
+
+func TestStateSnapshot(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+	primary := room.primary
+
+	room.fire(primary, Vec2{x: 1, y: 1}) // Hit four-deck
+	room.fire(primary, Vec2{x: 1, y: 7}) // Sink single-deck
+
+	snapshot := primary.snapshot()
+	if snapshot.Role != PRIMARY || snapshot.Turn != PRIMARY || len(snapshot.Battlefields) != 2 {
+		t.Fatalf("Snapshot has unexpected values: %+v", snapshot)
+	}
+
+	own := snapshot.Battlefields[0]
+	if len(own.Entities) != 10 || !own.Ready {
+		t.Errorf("Own fleet is not fully revealed in snapshot")
+	}
+
+	enemy := snapshot.Battlefields[1]
+	if len(enemy.Entities) != 1 || enemy.Entities[0].Type_ != SINGLEDECK || !enemy.Entities[0].Destroyed {
+		t.Errorf("Enemy battlefield reveals not only sunk ships: %+v", enemy.Entities)
+	}
+
+	hits := 0
+	for _, cell := range enemy.Cells {
+		if cell.Type_ == X_MARK {
+			hits++
+		}
+	}
+	if hits != 2 || len(enemy.Cells) <= 2 {
+		t.Errorf("Enemy battlefield has unexpected revealed cells: %+v", enemy.Cells)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "maps"
+
+// snapshot describes the whole room state as it is seen by the player or spectator
+func (pl *Player) snapshot() StocStateSnapshot {
+	room := pl.room
+	snapshot := StocStateSnapshot{
+		Role:       pl.role,
+		Gamestate_: room.gamestate,
+		Turn:       room.turn,
+		Rules:      room.rules.toStoc(),
+	}
+	if !room.playing() {
+		snapshot.Turn = 0
+	}
+	if !room.turnDeadline.IsZero() {
+		snapshot.TurnDeadline = room.turnDeadline.UnixMilli()
+	}
+
+	for _, player := range room.players() {
+		snapshot.Battlefields = append(snapshot.Battlefields, player.battlefieldSnapshot(teamOf(player.role) == teamOf(pl.role) || pl.fullReveal))
+	}
+	return snapshot
+}
+
+// battlefieldSnapshot describes player battlefield. Ships which are not sunk are revealed only to the owner team
+func (pl *Player) battlefieldSnapshot(owner bool) StocBattlefieldSnapshot {
+	battlefield := StocBattlefieldSnapshot{
+		Role:  pl.role,
+		Name:  pl.name,
+		Ready: pl.ready,
+
+		Weapons: maps.Clone(pl.weapons), // Snapshot must not change once it is queued for spectators
+	}
+
+	for _, entity := range pl.entities {
+		if !owner && !entity.destroyed() {
+			continue
+		}
+
+		snapshotEntity := StocEntitySnapshot{
+			Type_:     entity.type_,
+			Direction: entity.direction,
+			Destroyed: entity.destroyed(),
+		}
+		snapshotEntity.Position.X = entity.position.x
+		snapshotEntity.Position.Y = entity.position.y
+		for _, point := range entity.destroyedAbsPoints() {
+			snapshotEntity.DestroyedPoints = append(snapshotEntity.DestroyedPoints, StocPoint{X: point.x, Y: point.y})
+		}
+		battlefield.Entities = append(battlefield.Entities, snapshotEntity)
+	}
+
+	for _, point := range pl.shotPoints {
+		cell := StocCellSnapshot{
+			Type_: pl.shotCellType(point),
+		}
+		cell.Position.X = point.x
+		cell.Position.Y = point.y
+		battlefield.Cells = append(battlefield.Cells, cell)
+	}
+	for _, point := range pl.revealedPoints {
+		cell := StocCellSnapshot{
+			Type_: REVEALED_CELL,
+		}
+		cell.Position.X = point.x
+		cell.Position.Y = point.y
+		battlefield.Cells = append(battlefield.Cells, cell)
+	}
+
+	return battlefield
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"time"
+)
+
+// Spectators do not own any battlefield
+const SPECTATOR PlayerRoleType = 0
+
+type delayedEvent struct {
+	at   time.Time
+	code EventCode
+	line []byte // Encoded when queued, so data is not read after room mutex is unlocked
+}
+
+func (pl *Player) spectating() bool {
+	return pl.isInRoom() && pl.role == SPECTATOR
+}
+
+// addSpectator lets player watch the game. Full reveal spectators see every ship,
+// but all events are delivered to them with SpectatorRevealDelay so they can't tip players off
+func (room *Room) addSpectator(player *Player, fullReveal bool) bool {
+	if len(room.spectators) >= room.cfg.MaxSpectators {
+		return false
+	}
+
+	player.setRoom(room)
+	player.role = SPECTATOR
+	player.fullReveal = fullReveal
+	if fullReveal {
+		player.startDelayedEvents(room.cfg.SpectatorRevealDelay)
+	}
+	room.spectators = append(room.spectators, player)
+
+	response := StocSpectateRoom{
+		Rules:      room.rules.toStoc(),
+		FullReveal: fullReveal,
+		Players:    room.teamPlayers(),
+	}
+	if room.primary != nil {
+		response.PrimaryName = room.primary.name
+	}
+	if room.secondary != nil {
+		response.SecondaryName = room.secondary.name
+	}
+	if fullReveal {
+		response.RevealDelay = int(room.cfg.SpectatorRevealDelay.Seconds())
+	}
+	player.send(SPECTATE_ROOM, response)
+	player.sendSnapshot()
+
+	room.logInfo("%s is spectating (full reveal: %t)", player.name, fullReveal)
+	return true
+}
+
+func (room *Room) removeSpectator(player *Player) {
+	for i, spectator := range room.spectators {
+		if spectator == player {
+			room.spectators = append(room.spectators[:i], room.spectators[i+1:]...)
+			break
+		}
+	}
+	player.stopDelayedEvents()
+	player.setRoom(nil)
+}
+
+// announceToSpectators sends event to spectators. Secret events (revealing ships which are not sunk yet)
+// are sent only to full reveal spectators
+func (room *Room) announceToSpectators(code EventCode, data any, secret bool) {
+	for _, spectator := range room.spectators {
+		if secret && !spectator.fullReveal {
+			continue
+		}
+		spectator.spectate(code, data)
+	}
+}
+
+// spectate sends event to spectator, delaying it if needed
+func (pl *Player) spectate(code EventCode, data any) {
+	if !pl.fullReveal || pl.delayedEvents == nil {
+		pl.send(code, data)
+		return
+	}
+
+	select {
+	case pl.delayedEvents <- delayedEvent{at: time.Now().Add(pl.revealDelay), code: code, line: pl.encodeEvent(code, data)}:
+	default:
+		pl.logInfo("Delayed events queue is full, dropping event %d", code)
+	}
+}
+
+// sendSnapshot sends state snapshot, which is delayed for full reveal spectators as it reveals all ships
+func (pl *Player) sendSnapshot() {
+	if pl.spectating() {
+		pl.spectate(STATE_SNAPSHOT, pl.snapshot())
+		return
+	}
+	pl.send(STATE_SNAPSHOT, pl.snapshot())
+}
+
+func (pl *Player) startDelayedEvents(delay time.Duration) {
+	pl.revealDelay = delay
+	pl.delayedEvents = make(chan delayedEvent, MAX_DELAYED_EVENTS_COUNT)
+	pl.delayedDone = make(chan struct{})
+
+	go func(events chan delayedEvent, done chan struct{}) {
+		for {
+			var event delayedEvent
+			select {
+			case <-done:
+				return
+			case event = <-events:
+			}
+
+			timer := time.NewTimer(time.Until(event.at))
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			// Checked under connMtx, so nothing is sent once stopDelayedEvents has returned
+			pl.connMtx.Lock()
+			select {
+			case <-done:
+			default:
+				pl.writeLocked(event.code, event.line)
+			}
+			pl.connMtx.Unlock()
+		}
+	}(pl.delayedEvents, pl.delayedDone)
+}
+
+// stopDelayedEvents drops events which are still queued, room mutex has to be locked
+func (pl *Player) stopDelayedEvents() {
+	if pl.delayedDone == nil {
+		return
+	}
+	pl.connMtx.Lock()
+	close(pl.delayedDone)
+	pl.connMtx.Unlock()
+	pl.delayedDone = nil
+	pl.delayedEvents = nil
+}
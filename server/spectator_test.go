@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpectatorFogOfWar(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	spectator := newTestPlayer(cfg, "spectator")
+	if !room.addSpectator(spectator, false) || !spectator.spectating() {
+		t.Fatalf("Could not add spectator")
+	}
+
+	room.fire(room.primary, Vec2{x: 1, y: 7}) // Sink single-deck
+
+	for _, battlefield := range spectator.snapshot().Battlefields {
+		for _, entity := range battlefield.Entities {
+			if !entity.Destroyed {
+				t.Errorf("Spectator sees ship which is not sunk: %+v", entity)
+			}
+		}
+	}
+
+	room.removeSpectator(spectator)
+	if spectator.isInRoom() || len(room.spectators) != 0 {
+		t.Errorf("Spectator was not removed from room")
+	}
+}
+
+func TestSpectatorFullReveal(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	spectator := newTestPlayer(cfg, "spectator")
+	room.addSpectator(spectator, true)
+
+	for _, battlefield := range spectator.snapshot().Battlefields {
+		if len(battlefield.Entities) != 10 {
+			t.Errorf("Full reveal spectator does not see whole fleet")
+		}
+	}
+}
+
+func TestSpectatorsLimit(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.MaxSpectators = 1
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	if !room.addSpectator(newTestPlayer(cfg, "first"), false) {
+		t.Errorf("Spectator was not added")
+	}
+	if room.addSpectator(newTestPlayer(cfg, "second"), false) {
+		t.Errorf("Spectator was added even if limit is reached")
+	}
+}
+
+func TestSpectatorSecretEvents(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SpectatorRevealDelay = 0
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	fog, full := newTestPlayer(cfg, "fog"), newTestPlayer(cfg, "full")
+	fogEvents, fullEvents := connectTestPlayer(t, fog), connectTestPlayer(t, full)
+	room.addSpectator(fog, false)
+	room.addSpectator(full, true)
+
+	room.announceToSpectators(ADD_ENTITY, newStocAddEntity(PRIMARY, FOURDECK, Vec2{x: 1, y: 1}, HORIZONTAL), true)
+	room.announceToSpectators(SET_TURN, StocSetTurn{Role: PRIMARY}, false)
+
+	for _, code := range []EventCode{SPECTATE_ROOM, STATE_SNAPSHOT, SET_TURN} {
+		nextTestEvent(t, fogEvents, code)
+	}
+	for _, code := range []EventCode{SPECTATE_ROOM, STATE_SNAPSHOT, ADD_ENTITY, SET_TURN} {
+		nextTestEvent(t, fullEvents, code)
+	}
+}
+
+func TestSpectatorDelayedEvents(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SpectatorRevealDelay = 100 * time.Millisecond
+	room := newTestPlayingRoom(t, cfg, defaultRules())
+	defer room.destroy()
+
+	spectator := newTestPlayer(cfg, "spectator")
+	events := connectTestPlayer(t, spectator)
+	queuedAt := time.Now()
+	room.addSpectator(spectator, true)
+
+	nextTestEvent(t, events, SPECTATE_ROOM)
+	nextTestEvent(t, events, STATE_SNAPSHOT)
+	if delay := time.Since(queuedAt); delay < cfg.SpectatorRevealDelay {
+		t.Errorf("Snapshot was delivered after %s, expected at least %s", delay, cfg.SpectatorRevealDelay)
+	}
+
+	// Events still queued are dropped once spectator has left
+	room.announce(SET_TURN, StocSetTurn{Role: PRIMARY})
+	room.removeSpectator(spectator)
+	select {
+	case event := <-events:
+		t.Errorf("Event %d was delivered after spectator has left", event.Code)
+	case <-time.After(3 * cfg.SpectatorRevealDelay):
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+type PlayerStats struct {
+	Nickname  string `json:"nickname"`
+	Wins      int    `json:"wins"`
+	Losses    int    `json:"losses"`
+	Shots     int    `json:"shots"`
+	Hits      int    `json:"hits"`
+	ShipsSunk int    `json:"shipsSunk"`
+	Rating    int    `json:"rating"` // Elo rating
+}
+
+func newPlayerStats(nickname string) *PlayerStats {
+	return &PlayerStats{
+		Nickname: nickname,
+		Rating:   INITIAL_RATING,
+	}
+}
+
+func (stats *PlayerStats) accuracy() float64 {
+	if stats.Shots == 0 {
+		return 0
+	}
+	return float64(stats.Hits) / float64(stats.Shots)
+}
+
+func (stats *PlayerStats) toStoc() StocPlayerStats {
+	return StocPlayerStats{
+		Nickname:  stats.Nickname,
+		Wins:      stats.Wins,
+		Losses:    stats.Losses,
+		Shots:     stats.Shots,
+		Hits:      stats.Hits,
+		Accuracy:  stats.accuracy(),
+		ShipsSunk: stats.ShipsSunk,
+		Rating:    stats.Rating,
+	}
+}
+
+// eloChange returns how many rating points winner takes from loser
+func eloChange(winnerRating int, loserRating int) int {
+	expected := 1 / (1 + math.Pow(10, float64(loserRating-winnerRating)/400))
+	return int(math.Round(ELO_K_FACTOR * (1 - expected)))
+}
+
+// StatsStore keeps players statistics by nickname in JSON file. Empty path keeps them in memory only
+type StatsStore struct {
+	mtx     sync.Mutex
+	path    string
+	players map[string]*PlayerStats
+}
+
+func newStatsStore(path string) (*StatsStore, error) {
+	store := &StatsStore{
+		path:    path,
+		players: map[string]*PlayerStats{},
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	var players []*PlayerStats
+	if err := loadJSONFile(path, &players); err != nil {
+		return nil, err
+	}
+	for _, stats := range players {
+		if stats.Rating == 0 { // Saved before ratings were introduced
+			stats.Rating = INITIAL_RATING
+		}
+		store.players[stats.Nickname] = stats
+	}
+	return store, nil
+}
+
+func (store *StatsStore) get(nickname string) (PlayerStats, bool) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+
+	stats, ok := store.players[nickname]
+	if !ok {
+		return *newPlayerStats(nickname), false
+	}
+	return *stats, true
+}
+
+// recordGame adds results of a finished game to both players statistics, updates their ratings and saves the store.
+// Returns rating changes of winner and loser
+func (store *StatsStore) recordGame(winner *Player, loser *Player) ([]StocRatingChange, error) {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+
+	players := []*Player{winner, loser}
+	stats := make([]*PlayerStats, len(players))
+	for i, player := range players {
+		stats[i] = store.players[player.name]
+		if stats[i] == nil {
+			stats[i] = newPlayerStats(player.name)
+			store.players[player.name] = stats[i]
+		}
+	}
+
+	change := eloChange(stats[0].Rating, stats[1].Rating)
+	stats[0].Wins++
+	stats[1].Losses++
+
+	var changes []StocRatingChange
+	for i, player := range players {
+		stats[i].Shots += player.shots
+		stats[i].Hits += player.hits
+		stats[i].ShipsSunk += player.shipsSunk
+		delta := change
+		if player == loser {
+			delta = -change
+		}
+		stats[i].Rating += delta
+		changes = append(changes, StocRatingChange{
+			Role:     player.role,
+			Nickname: player.name,
+			Rating:   stats[i].Rating,
+			Change:   delta,
+		})
+	}
+
+	return changes, store.save()
+}
+
+// top returns best players ordered by wins, then by accuracy
+func (store *StatsStore) top(limit int) []PlayerStats {
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+
+	players := make([]PlayerStats, 0, len(store.players))
+	for _, stats := range store.players {
+		players = append(players, *stats)
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Wins != players[j].Wins {
+			return players[i].Wins > players[j].Wins
+		}
+		if players[i].accuracy() != players[j].accuracy() {
+			return players[i].accuracy() > players[j].accuracy()
+		}
+		return players[i].Nickname < players[j].Nickname
+	})
+
+	if len(players) > limit {
+		players = players[:limit]
+	}
+	return players
+}
+
+func (store *StatsStore) save() error {
+	if store.path == "" {
+		return nil
+	}
+
+	players := make([]*PlayerStats, 0, len(store.players))
+	for _, stats := range store.players {
+		players = append(players, stats)
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Nickname < players[j].Nickname })
+
+	return saveJSONFile(store.path, players)
+}
+
+// Replaced with file backed store in main()
+var STATS_STORE, _ = newStatsStore("")
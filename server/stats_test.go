@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStatsStore(t *testing.T) {
+	cfg := newTestConfig(t)
+	path := filepath.Join(t.TempDir(), "stats.json")
+	store, err := newStatsStore(path)
+	if err != nil {
+		t.Fatalf("Could not create stats store: %s", err)
+	}
+
+	winner := newTestPlayer(cfg, "winner")
+	winner.shots, winner.hits, winner.shipsSunk = 4, 3, 1
+	loser := newTestPlayer(cfg, "loser")
+	loser.shots = 2
+	changes, err := store.recordGame(winner, loser)
+	if err != nil {
+		t.Fatalf("Could not record game: %s", err)
+	}
+	if len(changes) != 2 || changes[0].Change != ELO_K_FACTOR/2 || changes[1].Change != -ELO_K_FACTOR/2 {
+		t.Errorf("Unexpected rating changes of equally rated players: %+v", changes)
+	}
+
+	store, err = newStatsStore(path)
+	if err != nil {
+		t.Fatalf("Could not load stats store: %s", err)
+	}
+
+	stats, ok := store.get("winner")
+	if !ok || stats.Wins != 1 || stats.Losses != 0 || stats.accuracy() != 0.75 || stats.ShipsSunk != 1 {
+		t.Errorf("Winner statistics were not saved: %+v", stats)
+	}
+	if stats, ok := store.get("loser"); !ok || stats.Losses != 1 || stats.Shots != 2 {
+		t.Errorf("Loser statistics were not saved: %+v", stats)
+	}
+
+	top := store.top(1)
+	if len(top) != 1 || top[0].Nickname != "winner" {
+		t.Errorf("Unexpected leaderboard: %+v", top)
+	}
+}
+
+func TestEloChange(t *testing.T) {
+	if change := eloChange(1500, 1500); change != ELO_K_FACTOR/2 {
+		t.Errorf("Unexpected rating change of equally rated players: %d", change)
+	}
+	if favorite, underdog := eloChange(1800, 1400), eloChange(1400, 1800); favorite >= underdog || favorite+underdog != ELO_K_FACTOR {
+		t.Errorf("Unexpected rating changes: favorite won %d, underdog won %d", favorite, underdog)
+	}
+}
@@ -8,19 +8,79 @@ type StocSecurityError struct {
 	Error string `json:"error"`
 }
 
+type StocInvalidRules struct {
+	Error string `json:"error"`
+}
+
 type StocCreateRoom struct {
-	RoomUid string `json:"roomUid"`
+	RoomUid      string `json:"roomUid"`
+	SessionToken string `json:"sessionToken"` // Used to resume session, see CtosResumeSession
+}
+
+type StocShipRule struct {
+	Type_ EntityType `json:"type"`
+	Size  struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"size"`
+	Cells []StocPoint `json:"cells,omitempty"` // Shape in horizontal starting from 1:1, omitted for rectangles
+	Count int         `json:"count"`
+}
+
+type StocRules struct {
+	Width  int            `json:"width"`
+	Height int            `json:"height"`
+	Fleet  []StocShipRule `json:"fleet"`
+	Salvo  bool           `json:"salvo"`
+
+	AllowTouching bool `json:"allowTouching"`
+
+	Mines      int        `json:"mines"`
+	Rocks      int        `json:"rocks"`
+	MineEffect MineEffect `json:"mineEffect"`
+
+	Weapons map[WeaponType]int `json:"weapons,omitempty"`
+
+	Teams        bool `json:"teams"`
+	SharedBoards bool `json:"sharedBoards"`
 }
 
 type StocJoinRoom struct {
-	PrimaryName   string `json:"primaryName"`
-	SecondaryName string `json:"secondaryName"`
+	PrimaryName   string           `json:"primaryName"`
+	SecondaryName string           `json:"secondaryName"`
+	Rules         StocRules        `json:"rules"`
+	SessionToken  string           `json:"sessionToken,omitempty"` // Token of the receiving player, see CtosResumeSession
+	Players       []StocTeamPlayer `json:"players,omitempty"`      // Team rooms only
+}
+
+type StocSpectateRoom struct {
+	PrimaryName   string           `json:"primaryName"`
+	SecondaryName string           `json:"secondaryName"`
+	Rules         StocRules        `json:"rules"`
+	FullReveal    bool             `json:"fullReveal"`
+	RevealDelay   int              `json:"revealDelay"`       // In seconds, all events are delayed for full reveal spectators
+	Players       []StocTeamPlayer `json:"players,omitempty"` // Team rooms only
+}
+
+type StocTeamPlayer struct {
+	Role PlayerRoleType `json:"role"`
+	Name string         `json:"name"`
+	Team PlayerRoleType `json:"team"` // Role of the team leader
 }
 
 type StocPlayerDisconnected struct {
 	Role PlayerRoleType `json:"role"`
 }
 
+type StocPlayerConnectionLost struct {
+	Role        PlayerRoleType `json:"role"`
+	GracePeriod int            `json:"gracePeriod"` // In seconds
+}
+
+type StocPlayerReconnected struct {
+	Role PlayerRoleType `json:"role"`
+}
+
 type StocSetGamestate struct {
 	Gamestate_ Gamestate `json:"gamestate"`
 }
@@ -29,6 +89,10 @@ type StocPlayerReadyToPlay struct {
 	Role PlayerRoleType `json:"role"`
 }
 
+type StocCancelReady struct {
+	Role PlayerRoleType `json:"role"`
+}
+
 type StocClearBattlefield struct {
 	Role  PlayerRoleType `json:"role"`
 	Start struct {
@@ -41,6 +105,46 @@ type StocClearBattlefield struct {
 	} `json:"end_"`
 }
 
+func newStocClearBattlefield(role PlayerRoleType, area Vec2x2) StocClearBattlefield {
+	sendEvent := StocClearBattlefield{
+		Role: role,
+	}
+	sendEvent.Start.X = area.start.x
+	sendEvent.Start.Y = area.start.y
+	sendEvent.End.X = area.end.x
+	sendEvent.End.Y = area.end.y
+	return sendEvent
+}
+
+type StocInvalidEntity struct {
+	Error string `json:"error"`
+}
+
+type StocMineExploded struct {
+	Role     PlayerRoleType `json:"role"` // Shooter
+	Effect   MineEffect     `json:"effect"`
+	Position StocPoint      `json:"position"` // Mine position on enemy battlefield
+}
+
+type StocUseWeapon struct {
+	Role      PlayerRoleType `json:"role"`
+	Weapon    WeaponType     `json:"weapon"`
+	Position  StocPoint      `json:"position"`
+	Direction DirectionType  `json:"direction"`
+	Remaining int            `json:"remaining"` // Uses of this weapon left to the player
+}
+
+type StocSalvoShot struct {
+	X      int        `json:"x"`
+	Y      int        `json:"y"`
+	Result ShotResult `json:"result"` // SHOT_INVALID if cell was revealed around ship sunk earlier in the same salvo
+}
+
+type StocSalvo struct {
+	Role  PlayerRoleType  `json:"role"`
+	Shots []StocSalvoShot `json:"shots"`
+}
+
 type StocAddEntity struct {
 	Role   PlayerRoleType `json:"role"`
 	Entity struct {
@@ -53,14 +157,140 @@ type StocAddEntity struct {
 	} `json:"entity"`
 }
 
+func newStocAddEntity(role PlayerRoleType, type_ EntityType, position Vec2, direction DirectionType) StocAddEntity {
+	sendEvent := StocAddEntity{
+		Role: role,
+	}
+	sendEvent.Entity.Type_ = type_
+	sendEvent.Entity.Position.X = position.x
+	sendEvent.Entity.Position.Y = position.y
+	sendEvent.Entity.Direction = direction
+	return sendEvent
+}
+
 type StocSetTurn struct {
 	Role PlayerRoleType `json:"role"`
 }
 
+type StocSetTurnDeadline struct {
+	Role     PlayerRoleType `json:"role"`
+	Deadline int64          `json:"deadline"` // Unix time in milliseconds
+	Timeout  int            `json:"timeout"`  // In seconds
+}
+
+type StocTurnTimeoutExceeded struct {
+	Role        PlayerRoleType `json:"role"`
+	MissedTurns int            `json:"missedTurns"`
+}
+
 type StocPlayerWin struct {
-	Role PlayerRoleType `json:"role"`
+	Role    PlayerRoleType `json:"role"`    // In team rooms the whole team of this player wins
+	Forfeit bool           `json:"forfeit"` // Opponent has missed too many turns
 }
 
 type StocRevengeRequested struct {
 	Role PlayerRoleType `json:"role"`
 }
+
+type StocPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type StocEntitySnapshot struct {
+	Type_           EntityType    `json:"type"`
+	Position        StocPoint     `json:"position"`
+	Direction       DirectionType `json:"direction"`
+	Destroyed       bool          `json:"destroyed"`
+	DestroyedPoints []StocPoint   `json:"destroyedPoints"` // Absolute positions of damaged cells
+}
+
+type StocCellSnapshot struct {
+	Type_    EntityType `json:"type"` // X_MARK or EMPTY_CELL
+	Position StocPoint  `json:"position"`
+}
+
+type StocBattlefieldSnapshot struct {
+	Role     PlayerRoleType       `json:"role"`
+	Name     string               `json:"name"`
+	Ready    bool                 `json:"ready"`
+	Entities []StocEntitySnapshot `json:"entities"`          // Whole fleet for own battlefield, only sunk ships otherwise
+	Cells    []StocCellSnapshot   `json:"cells"`             // Cells revealed by shots
+	Weapons  map[WeaponType]int   `json:"weapons,omitempty"` // Uses of every weapon left
+}
+
+type StocStateSnapshot struct {
+	Role         PlayerRoleType            `json:"role"` // Role of the receiver
+	Gamestate_   Gamestate                 `json:"gamestate"`
+	Turn         PlayerRoleType            `json:"turn"`         // 0 if not in playing stage
+	TurnDeadline int64                     `json:"turnDeadline"` // Unix time in milliseconds, 0 if shot clock is disabled
+	Rules        StocRules                 `json:"rules"`
+	Battlefields []StocBattlefieldSnapshot `json:"battlefields"`
+}
+
+type StocPlayerStats struct {
+	Nickname  string  `json:"nickname"`
+	Wins      int     `json:"wins"`
+	Losses    int     `json:"losses"`
+	Shots     int     `json:"shots"`
+	Hits      int     `json:"hits"`
+	Accuracy  float64 `json:"accuracy"` // 0..1
+	ShipsSunk int     `json:"shipsSunk"`
+	Rating    int     `json:"rating"`
+}
+
+type StocLeaderboard struct {
+	Players []StocPlayerStats `json:"players"`
+}
+
+type StocAuthenticated struct {
+	Nickname string `json:"nickname"` // As registered
+	Token    string `json:"token"`    // Can be used to log in instead of password
+}
+
+type StocMatchQueue struct {
+	Position      int `json:"position"`      // Starting from 1
+	EstimatedWait int `json:"estimatedWait"` // In seconds, -1 if unknown
+}
+
+type StocMatchFound struct {
+	RoomUid string         `json:"roomUid"`
+	Role    PlayerRoleType `json:"role"`
+}
+
+type StocRatingChange struct {
+	Role     PlayerRoleType `json:"role"`
+	Nickname string         `json:"nickname"`
+	Rating   int            `json:"rating"` // New rating
+	Change   int            `json:"change"`
+}
+
+type StocRatingUpdate struct {
+	Players []StocRatingChange `json:"players"`
+}
+
+type StocRoomInfo struct {
+	RoomUid    string    `json:"roomUid"`
+	HostName   string    `json:"hostName"`
+	Players    int       `json:"players"`
+	Locked     bool      `json:"locked"`   // Room is protected with password
+	Joinable   bool      `json:"joinable"` // Room has a free slot and game has not started, otherwise it can only be spectated
+	Gamestate_ Gamestate `json:"gamestate"`
+	Rules      StocRules `json:"rules"`
+	CreatedAt  int64     `json:"createdAt"` // Unix time in milliseconds
+}
+
+type StocListRooms struct {
+	Rooms      []StocRoomInfo `json:"rooms"`
+	Page       int            `json:"page"`
+	PagesCount int            `json:"pagesCount"`
+	Total      int            `json:"total"`
+}
+
+type StocTooManyAttempts struct {
+	RetryAfter int `json:"retryAfter"` // In seconds, see LOGIN_LIMITER and REGISTER_LIMITER
+}
+
+type StocInvalidRoomPassword struct {
+	RetryAfter int `json:"retryAfter"` // In seconds, 0 if player can try again right now
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Strategy makes moves of a bot player. It's given the same information as a human would have
+// and its moves are checked by the same rules as moves of human players
+type Strategy interface {
+	newGame(rules *Rules)                                // Forget everything about previous game
+	placeFleet(rules *Rules) ([]Entity, error)           // Whole fleet, it's checked with Player.addEntity as fleet of human player
+	chooseShot() (Vec2, bool)                            // Point of enemy battlefield which was never shot before, false if strategy has none
+	observe(point Vec2, result ShotResult, sunk *Entity) // Result of the last shot. Sunk ship is revealed to shooter, so it's passed too
+}
+
+type BotDifficulty string
+
+// Known strategies. Add a constructor here to make new strategy available for CREATE_AI_ROOM and tournaments
+var STRATEGIES = map[BotDifficulty]func() Strategy{
+	BOT_RANDOM:      func() Strategy { return &RandomStrategy{} },
+	BOT_HUNT_TARGET: func() Strategy { return &HuntTargetStrategy{} },
+	BOT_PROBABILITY: func() Strategy { return &ProbabilityStrategy{} },
+}
+
+const (
+	BOT_RANDOM      BotDifficulty = "random"      // Shoots at random cells
+	BOT_HUNT_TARGET BotDifficulty = "hunt"        // Shoots at checkerboard cells until hit, then finishes the ship off
+	BOT_PROBABILITY BotDifficulty = "probability" // Shoots at cells most likely occupied by ships which are not sunk yet
+)
+
+func newStrategy(difficulty BotDifficulty) (Strategy, error) {
+	constructor, ok := STRATEGIES[difficulty]
+	if !ok {
+		return nil, fmt.Errorf("unknown bot difficulty: %q", difficulty)
+	}
+	return constructor(), nil
+}
+
+// strategyNames returns names of known strategies in alphabetical order
+func strategyNames() []BotDifficulty {
+	names := make([]BotDifficulty, 0, len(STRATEGIES))
+	for name := range STRATEGIES {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// What bot knows about enemy battlefield cell
+type botCell int
+
+const (
+	BOT_CELL_UNKNOWN botCell = 0
+	BOT_CELL_EMPTY   botCell = 1 // Missed or revealed around sunk ship
+	BOT_CELL_HIT     botCell = 2 // Ship is hit, but not sunk yet
+	BOT_CELL_SUNK    botCell = 3
+)
+
+// botKnowledge keeps what strategy knows about enemy battlefield and places fleet at random.
+// Strategies embed it and implement chooseShot only
+type botKnowledge struct {
+	rules     *Rules
+	cells     map[Vec2]botCell
+	remaining map[EntityType]int // Ships which are not sunk yet
+}
+
+func (bot *botKnowledge) newGame(rules *Rules) {
+	bot.rules = rules
+	bot.cells = map[Vec2]botCell{}
+	bot.remaining = map[EntityType]int{}
+	for type_, ship := range rules.Fleet {
+		bot.remaining[type_] = ship.Count
+	}
+}
+
+// placeFleet places fleet at random on a scratch battlefield, strategy is given nothing but the rules
+func (bot *botKnowledge) placeFleet(rules *Rules) ([]Entity, error) {
+	board := newOfflineRoom(nil, *rules, "", "").primary
+	if err := board.placeRandomFleet(); err != nil {
+		return nil, err
+	}
+	entities := make([]Entity, 0, len(board.entities))
+	for _, entity := range board.entities {
+		entities = append(entities, *entity)
+	}
+	return entities, nil
+}
+
+func (bot *botKnowledge) observe(point Vec2, result ShotResult, sunk *Entity) {
+	switch result {
+	case SHOT_MISS, SHOT_MINE:
+		bot.cells[point] = BOT_CELL_EMPTY
+	case SHOT_HIT:
+		bot.cells[point] = BOT_CELL_HIT
+		if bot.rules.AllowTouching || !bot.rules.straightFleet() {
+			return
+		}
+		for _, dx := range []int{-1, 1} { // Ships can't touch each other, so diagonal cells are empty
+			for _, dy := range []int{-1, 1} {
+				if diagonal := (Vec2{x: point.x + dx, y: point.y + dy}); bot.cells[diagonal] == BOT_CELL_UNKNOWN {
+					bot.cells[diagonal] = BOT_CELL_EMPTY
+				}
+			}
+		}
+	case SHOT_SUNK:
+		if sunk == nil {
+			bot.cells[point] = BOT_CELL_HIT
+			return
+		}
+
+		if !bot.rules.AllowTouching {
+			for _, point := range sunk.haloPoints() {
+				if bot.cells[point] == BOT_CELL_UNKNOWN {
+					bot.cells[point] = BOT_CELL_EMPTY
+				}
+			}
+		}
+		for _, point := range sunk.absPoints() {
+			bot.cells[point] = BOT_CELL_SUNK
+		}
+		bot.remaining[sunk.type_]--
+	}
+}
+
+func (bot *botKnowledge) unknownCells() []Vec2 {
+	var points []Vec2
+	for x := 1; x <= bot.rules.Width; x++ {
+		for y := 1; y <= bot.rules.Height; y++ {
+			if point := (Vec2{x: x, y: y}); bot.unknown(point) {
+				points = append(points, point)
+			}
+		}
+	}
+	return points
+}
+
+func (bot *botKnowledge) unknown(point Vec2) bool {
+	return bot.rules.inBounds(point) && bot.cells[point] == BOT_CELL_UNKNOWN
+}
+
+func (bot *botKnowledge) hits() []Vec2 {
+	var hits []Vec2
+	for point, cell := range bot.cells {
+		if cell == BOT_CELL_HIT {
+			hits = append(hits, point)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { // Map order is random, keep choice reproducible
+		return hits[i].x < hits[j].x || hits[i].x == hits[j].x && hits[i].y < hits[j].y
+	})
+	return hits
+}
+
+func (bot *botKnowledge) randomShot() (Vec2, bool) {
+	return randomPoint(bot.unknownCells())
+}
+
+type RandomStrategy struct {
+	botKnowledge
+}
+
+func (bot *RandomStrategy) chooseShot() (Vec2, bool) {
+	return bot.randomShot()
+}
+
+type HuntTargetStrategy struct {
+	botKnowledge
+}
+
+var botDirections = []Vec2{{x: 1}, {x: -1}, {y: 1}, {y: -1}}
+
+// chooseShot finishes off hit ship, preferring cells continuing line of hits.
+// If there is no such ship, shoots at checkerboard cells, as every ship covers at least one of them
+func (bot *HuntTargetStrategy) chooseShot() (Vec2, bool) {
+	var line, around []Vec2
+	for _, hit := range bot.hits() {
+		for _, direction := range botDirections {
+			next := Vec2{x: hit.x + direction.x, y: hit.y + direction.y}
+			if !bot.unknown(next) {
+				continue
+			}
+			if bot.cells[Vec2{x: hit.x - direction.x, y: hit.y - direction.y}] == BOT_CELL_HIT {
+				line = append(line, next)
+			} else {
+				around = append(around, next)
+			}
+		}
+	}
+	if point, ok := randomPoint(line); ok {
+		return point, true
+	}
+	if point, ok := randomPoint(around); ok {
+		return point, true
+	}
+
+	step := bot.shortestRemainingShip()
+	var checkerboard []Vec2
+	for _, point := range bot.unknownCells() {
+		if (point.x+point.y)%step == 0 {
+			checkerboard = append(checkerboard, point)
+		}
+	}
+	if point, ok := randomPoint(checkerboard); ok {
+		return point, true
+	}
+	return bot.randomShot()
+}
+
+func (bot *HuntTargetStrategy) shortestRemainingShip() int {
+	shortest := 0
+	for type_, count := range bot.remaining {
+		if count <= 0 {
+			continue
+		}
+		ship := bot.rules.Fleet[type_]
+		length := max(ship.Size.x, ship.Size.y)
+		if ship.Cells != nil { // Every shape of two cells or more covers both checkerboard colors
+			length = min(length, 2)
+		}
+		if shortest == 0 || length < shortest {
+			shortest = length
+		}
+	}
+	return max(shortest, 1)
+}
+
+type ProbabilityStrategy struct {
+	botKnowledge
+}
+
+// chooseShot counts every placement of ships which are not sunk yet consistent with known cells
+// and shoots at cell covered by the most of them. Placements covering hit cells are preferred
+func (bot *ProbabilityStrategy) chooseShot() (Vec2, bool) {
+	density := bot.density(true)
+	if len(density) == 0 {
+		density = bot.density(false)
+	}
+
+	var best []Vec2
+	bestDensity := 0
+	for x := 1; x <= bot.rules.Width; x++ {
+		for y := 1; y <= bot.rules.Height; y++ {
+			point := Vec2{x: x, y: y}
+			if density[point] > bestDensity {
+				best = []Vec2{point}
+				bestDensity = density[point]
+			} else if density[point] == bestDensity && bestDensity != 0 {
+				best = append(best, point)
+			}
+		}
+	}
+	if point, ok := randomPoint(best); ok {
+		return point, true
+	}
+	return bot.randomShot()
+}
+
+func (bot *ProbabilityStrategy) density(targetHits bool) map[Vec2]int {
+	targetHits = targetHits && len(bot.hits()) != 0
+	density := map[Vec2]int{}
+	for type_, count := range bot.remaining {
+		if count <= 0 {
+			continue
+		}
+		for x := 1; x <= bot.rules.Width; x++ {
+			for y := 1; y <= bot.rules.Height; y++ {
+				for _, direction := range bot.rules.directions(type_) {
+					entity, err := newEntity(bot.rules, type_, Vec2{x: x, y: y}, direction)
+					if err != nil {
+						continue
+					}
+
+					points := entity.absPoints()
+					possible, coveredHits := true, 0
+					for _, point := range points {
+						switch bot.cells[point] {
+						case BOT_CELL_EMPTY, BOT_CELL_SUNK:
+							possible = false
+						case BOT_CELL_HIT:
+							coveredHits++
+						}
+					}
+					if !possible || targetHits && coveredHits == 0 || bot.touchesHit(entity) {
+						continue
+					}
+
+					weight := count * (1 + coveredHits*BOT_HIT_WEIGHT)
+					for _, point := range points {
+						if bot.cells[point] == BOT_CELL_UNKNOWN {
+							density[point] += weight
+						}
+					}
+				}
+			}
+		}
+	}
+	return density
+}
+
+// touchesHit reports if there are hit cells around entity, such placement is impossible as ships can't touch
+func (bot *ProbabilityStrategy) touchesHit(entity Entity) bool {
+	if bot.rules.AllowTouching {
+		return false
+	}
+	for _, point := range entity.haloPoints() {
+		if bot.cells[point] == BOT_CELL_HIT {
+			return true
+		}
+	}
+	return false
+}
+
+// randomPoint returns random point of the list, false if it's empty
+func randomPoint(points []Vec2) (Vec2, bool) {
+	if len(points) == 0 {
+		return Vec2{}, false
+	}
+	return points[rand.Intn(len(points))], true
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Teammates of PRIMARY and SECONDARY in team rooms, see Rules.Teams
+const (
+	TERTIARY   PlayerRoleType = 3
+	QUATERNARY PlayerRoleType = 4
+)
+
+// teamOf returns role of the team leader, teams are named after their leaders
+func teamOf(role PlayerRoleType) PlayerRoleType {
+	switch role {
+	case PRIMARY, TERTIARY:
+		return PRIMARY
+	case SECONDARY, QUATERNARY:
+		return SECONDARY
+	}
+	return SPECTATOR
+}
+
+// opposingTeam returns team the role plays against
+func opposingTeam(role PlayerRoleType) PlayerRoleType {
+	switch teamOf(role) {
+	case PRIMARY:
+		return SECONDARY
+	case SECONDARY:
+		return PRIMARY
+	}
+	return SPECTATOR
+}
+
+// roles returns roles of room slots in turn order
+func (room *Room) roles() []PlayerRoleType {
+	if room.rules.Teams {
+		return []PlayerRoleType{PRIMARY, SECONDARY, TERTIARY, QUATERNARY}
+	}
+	return []PlayerRoleType{PRIMARY, SECONDARY}
+}
+
+func (room *Room) full() bool {
+	return len(room.players()) == len(room.roles())
+}
+
+// boards returns players of the team who own a battlefield
+func (room *Room) boards(team PlayerRoleType) []*Player {
+	var boards []*Player
+	for _, player := range room.players() {
+		if teamOf(player.role) == team && player.ownsBoard() {
+			boards = append(boards, player)
+		}
+	}
+	return boards
+}
+
+// ownsBoard reports if player defends his own battlefield. With shared battlefields teammates defend the leader one
+func (pl *Player) ownsBoard() bool {
+	return !pl.rules().SharedBoards || pl.role == teamOf(pl.role)
+}
+
+// board returns player whose battlefield player defends
+func (pl *Player) board() *Player {
+	if pl.ownsBoard() {
+		return pl
+	}
+	return pl.room.player(teamOf(pl.role))
+}
+
+// team returns players of the player team, including himself
+func (pl *Player) team() []*Player {
+	var team []*Player
+	for _, player := range pl.room.players() {
+		if teamOf(player.role) == teamOf(pl.role) {
+			team = append(team, player)
+		}
+	}
+	return team
+}
+
+// opponents returns players of the opposing team
+func (pl *Player) opponents() []*Player {
+	var opponents []*Player
+	for _, player := range pl.room.players() {
+		if teamOf(player.role) == opposingTeam(pl.role) {
+			opponents = append(opponents, player)
+		}
+	}
+	return opponents
+}
+
+func (pl *Player) sendToTeam(code EventCode, data any) {
+	for _, player := range pl.team() {
+		player.send(code, data)
+	}
+}
+
+// teamDefeated reports if every battlefield of the player team is destroyed
+func (pl *Player) teamDefeated() bool {
+	for _, board := range pl.room.boards(teamOf(pl.role)) {
+		if !board.isTotallyDead() {
+			return false
+		}
+	}
+	return true
+}
+
+// targetEnemy returns battlefield player shoots at in team room: the chosen one while it's afloat,
+// otherwise the first one afloat
+func (pl *Player) targetEnemy() *Player {
+	boards := pl.room.boards(opposingTeam(pl.role))
+	for _, board := range boards {
+		if board.role == pl.target && !board.isTotallyDead() {
+			return board
+		}
+	}
+	for _, board := range boards {
+		if !board.isTotallyDead() {
+			return board
+		}
+	}
+	if len(boards) == 0 {
+		return nil
+	}
+	return boards[0]
+}
+
+// setTarget chooses opponent battlefield player shoots at, see targetEnemy
+func (pl *Player) setTarget(role PlayerRoleType) error {
+	if !pl.rules().Teams {
+		return errors.New("targets can be chosen only in team rooms")
+	}
+	target := pl.room.player(role)
+	if target == nil || teamOf(role) != opposingTeam(pl.role) || !target.ownsBoard() {
+		return fmt.Errorf("invalid target: %d", role)
+	}
+	if target.isTotallyDead() {
+		return fmt.Errorf("fleet of %s is already destroyed", target.name)
+	}
+	pl.target = role
+	return nil
+}
+
+// teamPlayers describes players occupying slots of team room, nil for other rooms
+func (room *Room) teamPlayers() []StocTeamPlayer {
+	if !room.rules.Teams {
+		return nil
+	}
+	var players []StocTeamPlayer
+	for _, player := range room.players() {
+		players = append(players, StocTeamPlayer{
+			Role: player.role,
+			Name: player.name,
+			Team: teamOf(player.role),
+		})
+	}
+	return players
+}
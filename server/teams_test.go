@@ -0,0 +1,137 @@
+package main
+
+import "testing"
+
+func newTeamTestRules(sharedBoards bool) Rules {
+	rules := defaultRules()
+	rules.Teams = true
+	rules.SharedBoards = sharedBoards
+	return rules
+}
+
+// sinkFleet makes shooter hit every ship cell of the board
+func sinkFleet(t *testing.T, room *Room, shooter *Player, board *Player) {
+	for _, entity := range board.entities {
+		for _, point := range entity.absPoints() {
+			if result := room.fire(shooter, point); result != SHOT_HIT && result != SHOT_SUNK {
+				t.Fatalf("Unexpected result %d of shot at %+v", result, point)
+			}
+		}
+	}
+}
+
+func TestTeamRules(t *testing.T) {
+	rules := defaultRules()
+	rules.SharedBoards = true
+	if rules.validate() == nil {
+		t.Errorf("Shared battlefields were allowed without teams")
+	}
+
+	rules.Teams = true
+	rules.Salvo = true
+	if rules.validate() == nil {
+		t.Errorf("Salvo was allowed in team room")
+	}
+}
+
+func TestTeamSlots(t *testing.T) {
+	cfg := newTestConfig(t)
+	room := createRoom(newTestPlayer(cfg, "primary"), newTeamTestRules(false))
+	defer room.destroy()
+
+	for _, name := range []string{"secondary", "tertiary", "quaternary"} {
+		if room.building() {
+			t.Fatalf("Building has started before room is full")
+		}
+		if !room.addPlayer(newTestPlayer(cfg, name)) {
+			t.Fatalf("Could not add %s", name)
+		}
+	}
+	if room.addPlayer(newTestPlayer(cfg, "extra")) {
+		t.Errorf("Fifth player was added to team room")
+	}
+	if !room.building() {
+		t.Errorf("Building has not started once room is full")
+	}
+	if teamOf(room.tertiary.role) != PRIMARY || teamOf(room.quaternary.role) != SECONDARY {
+		t.Errorf("Unexpected teams: %d, %d", teamOf(room.tertiary.role), teamOf(room.quaternary.role))
+	}
+}
+
+func TestTeamBattle(t *testing.T) {
+	room := newTestPlayingRoom(t, newTestConfig(t), newTeamTestRules(false))
+	defer room.destroy()
+	primary, secondary, tertiary, quaternary := room.primary, room.secondary, room.tertiary, room.quaternary
+
+	// Turn goes around all four players
+	for i, player := range []*Player{primary, secondary, tertiary, quaternary} {
+		if room.turn != player.role {
+			t.Fatalf("Expected turn of %d, got %d", player.role, room.turn)
+		}
+		if room.fire(player, Vec2{x: 10, y: 10 - i}) != SHOT_MISS { // Both players of a team shoot at the same enemy
+			t.Fatalf("%s has not missed", player.name)
+		}
+	}
+	if room.turn != PRIMARY {
+		t.Fatalf("Turn has not come back to primary")
+	}
+
+	if primary.setTarget(TERTIARY) == nil || primary.setTarget(SPECTATOR) == nil {
+		t.Errorf("Teammate or nobody was chosen as a target")
+	}
+	if err := primary.setTarget(QUATERNARY); err != nil {
+		t.Fatalf("Could not choose target: %s", err)
+	}
+	if primary.enemy() != quaternary {
+		t.Fatalf("Chosen target is not shot at")
+	}
+
+	// Destroyed fleet can't be chosen, shots go to the other one
+	sinkFleet(t, room, primary, quaternary)
+	if !room.playing() {
+		t.Fatalf("Game is over while secondary fleet is afloat")
+	}
+	if primary.setTarget(QUATERNARY) == nil {
+		t.Errorf("Destroyed fleet was chosen as a target")
+	}
+	if primary.enemy() != secondary {
+		t.Fatalf("Shots have not moved to the fleet afloat")
+	}
+
+	sinkFleet(t, room, primary, secondary)
+	if !room.over() || !secondary.teamDefeated() || primary.teamDefeated() {
+		t.Errorf("Game is not over after the whole team was destroyed")
+	}
+}
+
+func TestTeamSharedBoards(t *testing.T) {
+	room := newTestRoom(t, newTestConfig(t), newTeamTestRules(true))
+	defer room.destroy()
+	primary, secondary, tertiary, quaternary := room.primary, room.secondary, room.tertiary, room.quaternary
+
+	if tertiary.canEditFleet() {
+		t.Errorf("Teammate is allowed to build shared battlefield")
+	}
+	if tertiary.board() != primary || quaternary.board() != secondary {
+		t.Errorf("Teammates don't defend leader battlefields")
+	}
+
+	placeTestFleet(t, primary)
+	placeTestFleet(t, secondary)
+	if !room.startPlaying() {
+		t.Fatalf("Could not start playing with shared battlefields")
+	}
+	if tertiary.enemy() != secondary || quaternary.enemy() != primary {
+		t.Fatalf("Teammates don't shoot at leader battlefields")
+	}
+
+	room.fire(primary, Vec2{x: 10, y: 10})
+	room.fire(secondary, Vec2{x: 10, y: 10})
+	if room.turn != TERTIARY {
+		t.Fatalf("Expected turn of tertiary, got %d", room.turn)
+	}
+	sinkFleet(t, room, tertiary, secondary)
+	if !room.over() {
+		t.Errorf("Game is not over after shared battlefield was destroyed")
+	}
+}
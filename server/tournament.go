@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// TournamentStanding sums up games of a single strategy
+type TournamentStanding struct {
+	name     BotDifficulty
+	games    int
+	wins     int
+	winShots int // Total shots made in won games
+}
+
+func (standing *TournamentStanding) winRate() float64 {
+	if standing.games == 0 {
+		return 0
+	}
+	return float64(standing.wins) / float64(standing.games)
+}
+
+func (standing *TournamentStanding) averageShotsToWin() float64 {
+	if standing.wins == 0 {
+		return 0
+	}
+	return float64(standing.winShots) / float64(standing.wins)
+}
+
+// runTournament pits strategies against each other headlessly and prints their standings.
+// It's run as `seabattle tournament [flags]`
+func runTournament(args []string, output io.Writer) error {
+	fs := flag.NewFlagSet("seabattle tournament", flag.ContinueOnError)
+	fs.SetOutput(output)
+	games := fs.Int("games", TOURNAMENT_GAMES, "games played by every pair of strategies")
+	strategies := fs.String("strategies", "", "comma separated strategies to compete, empty = all known")
+	rulesPath := fs.String("rules", "", "path to JSON file with rules in CREATE_ROOM format, empty = default rules")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *games < 1 {
+		return errors.New("games count has to be positive")
+	}
+
+	names := strategyNames()
+	if *strategies != "" {
+		names = nil
+		for _, name := range strings.Split(*strategies, ",") {
+			name := BotDifficulty(strings.TrimSpace(name))
+			if _, ok := STRATEGIES[name]; !ok {
+				return fmt.Errorf("unknown strategy %q, known strategies: %v", name, strategyNames())
+			}
+			names = append(names, name)
+		}
+	}
+	if len(names) < 2 {
+		return errors.New("at least two strategies are required")
+	}
+
+	rules := defaultRules()
+	if *rulesPath != "" {
+		data, err := os.ReadFile(*rulesPath)
+		if err != nil {
+			return err
+		}
+		requested := CtosRules{}
+		if err := json.Unmarshal(data, &requested); err != nil {
+			return fmt.Errorf("%s: %w", *rulesPath, err)
+		}
+		if rules, err = newRules(&requested); err != nil {
+			return fmt.Errorf("%s: %w", *rulesPath, err)
+		}
+	}
+
+	standings, err := playTournament(defaultConfig(), rules, names, *games)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "strategy\tgames\twins\twin rate\tavg shots to win")
+	for _, standing := range standings {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%.1f%%\t%.1f\n",
+			standing.name, standing.games, standing.wins, standing.winRate()*100, standing.averageShotsToWin())
+	}
+	return writer.Flush()
+}
+
+// playTournament makes every pair of strategies play games, alternating who shoots first
+func playTournament(cfg *Config, rules Rules, names []BotDifficulty, games int) ([]*TournamentStanding, error) {
+	standings := make([]*TournamentStanding, len(names))
+	for i, name := range names {
+		standings[i] = &TournamentStanding{name: name}
+	}
+
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			for game := 0; game < games; game++ {
+				first, second := standings[i], standings[j]
+				if game%2 == 1 {
+					first, second = second, first
+				}
+
+				winner, err := playOfflineGame(cfg, rules, first.name, second.name)
+				if err != nil {
+					return nil, err
+				}
+
+				first.games++
+				second.games++
+				standing := first
+				if winner.role == SECONDARY {
+					standing = second
+				}
+				standing.wins++
+				standing.winShots += winner.shots
+			}
+		}
+	}
+	return standings, nil
+}
+
+// playOfflineGame plays a whole game of two strategies in offline room and returns the winner
+func playOfflineGame(cfg *Config, rules Rules, primary BotDifficulty, secondary BotDifficulty) (*Player, error) {
+	if rules.Teams {
+		return nil, errors.New("strategies play one on one, team rules can't be used")
+	}
+
+	room := newOfflineRoom(cfg, rules, string(primary), string(secondary))
+	for _, player := range room.players() {
+		bot, err := newStrategy(BotDifficulty(player.name))
+		if err != nil {
+			return nil, err
+		}
+		player.bot = bot
+	}
+
+	room.prepareBots()
+	if !room.startPlaying() {
+		return nil, errors.New("bots could not place their fleets")
+	}
+
+	for !room.over() {
+		shooter := room.player(room.turn)
+		if !room.botMove(shooter) {
+			return nil, fmt.Errorf("%s has made invalid move", shooter.name)
+		}
+	}
+
+	for _, player := range room.players() {
+		if !player.isTotallyDead() {
+			return player, nil
+		}
+	}
+	return nil, errors.New("both fleets are sunk")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlayTournament(t *testing.T) {
+	cfg := newTestConfig(t)
+	names := []BotDifficulty{BOT_RANDOM, BOT_PROBABILITY}
+	standings, err := playTournament(cfg, defaultRules(), names, 10)
+	if err != nil {
+		t.Fatalf("Tournament has failed: %s", err)
+	}
+
+	random, probability := standings[0], standings[1]
+	if random.games != 10 || probability.games != 10 || random.wins+probability.wins != 10 {
+		t.Errorf("Unexpected games count: %+v, %+v", random, probability)
+	}
+	if probability.wins <= random.wins {
+		t.Errorf("Probability strategy has lost to random one: %+v, %+v", random, probability)
+	}
+	if shots := probability.averageShotsToWin(); shots < 20 || shots > 100 {
+		t.Errorf("Unexpected average shots to win: %f", shots)
+	}
+
+	rules := defaultRules()
+	rules.Teams = true
+	if _, err := playTournament(cfg, rules, names, 1); err == nil {
+		t.Errorf("Tournament was played by team rules")
+	}
+}
+
+func TestRunTournament(t *testing.T) {
+	output := &bytes.Buffer{}
+	if err := runTournament([]string{"-games", "2"}, output); err != nil {
+		t.Fatalf("Tournament has failed: %s", err)
+	}
+	for _, name := range strategyNames() {
+		if !strings.Contains(output.String(), string(name)) {
+			t.Errorf("Strategy %s is missing in standings:\n%s", name, output)
+		}
+	}
+
+	if err := runTournament([]string{"-strategies", "random,genius"}, &bytes.Buffer{}); err == nil {
+		t.Errorf("Tournament with unknown strategy was played")
+	}
+}
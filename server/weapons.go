@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WeaponType is a limited-use alternative to a single shot, see USE_WEAPON
+type WeaponType string
+
+const (
+	WEAPON_BOMB    WeaponType = "bomb"    // Shoots at every cell of 3x3 area around the target
+	WEAPON_TORPEDO WeaponType = "torpedo" // Runs along the row (HORIZONTAL) or column (VERTICAL) of the target from the battlefield edge until it hits something. Rocks stop it
+	WEAPON_SONAR   WeaponType = "sonar"   // Tells if there are ship cells which are not hit yet in 3x3 area around the target, nothing is damaged
+)
+
+const MAX_WEAPON_USES = 5 // Per weapon type and player
+
+func validateWeapons(weapons map[WeaponType]int) error {
+	for weapon, count := range weapons {
+		if weapon != WEAPON_BOMB && weapon != WEAPON_TORPEDO && weapon != WEAPON_SONAR {
+			return fmt.Errorf("unknown weapon: %q", weapon)
+		}
+		if count < 0 || count > MAX_WEAPON_USES {
+			return fmt.Errorf("weapon %s count is out of 0..%d range", weapon, MAX_WEAPON_USES)
+		}
+	}
+	return nil
+}
+
+// weaponArea returns points of 3x3 area around the target which are inside battlefield
+func (rules *Rules) weaponArea(target Vec2) []Vec2 {
+	var points []Vec2
+	for x := target.x - 1; x <= target.x+1; x++ {
+		for y := target.y - 1; y <= target.y+1; y++ {
+			if point := (Vec2{x: x, y: y}); rules.inBounds(point) {
+				points = append(points, point)
+			}
+		}
+	}
+	return points
+}
+
+// torpedoPath returns points torpedo may shoot at, in order. It ends before the first rock
+func (enemy *Player) torpedoPath(target Vec2, direction DirectionType) []Vec2 {
+	rules := enemy.rules()
+	var line []Vec2
+	switch direction {
+	case HORIZONTAL:
+		for x := 1; x <= rules.Width; x++ {
+			line = append(line, Vec2{x: x, y: target.y})
+		}
+	case VERTICAL:
+		for y := 1; y <= rules.Height; y++ {
+			line = append(line, Vec2{x: target.x, y: y})
+		}
+	}
+
+	var path []Vec2
+	for _, point := range line {
+		if entity := enemy.entityAt(point); entity != nil && entity.type_ == ROCK {
+			break
+		}
+		if !enemy.isAlreadyShotAt(point) {
+			path = append(path, point)
+		}
+	}
+	return path
+}
+
+// useWeapon fires weapon of the shooter. Nothing is changed if error is returned. Room mutex has to be locked
+func (room *Room) useWeapon(shooter *Player, weapon WeaponType, target Vec2, direction DirectionType) error {
+	if shooter.weapons[weapon] <= 0 {
+		return fmt.Errorf("you have no %s left", weapon)
+	}
+	if !room.rules.inBounds(target) {
+		return errors.New("target is out of battlefield")
+	}
+
+	enemy := shooter.enemy()
+	var points []Vec2
+	switch weapon {
+	case WEAPON_BOMB:
+		for _, point := range room.rules.weaponArea(target) {
+			if !enemy.isAlreadyShotAt(point) {
+				points = append(points, point)
+			}
+		}
+	case WEAPON_TORPEDO:
+		if direction != HORIZONTAL && direction != VERTICAL {
+			return fmt.Errorf("incorrect torpedo direction: %d", direction)
+		}
+		points = enemy.torpedoPath(target, direction)
+	case WEAPON_SONAR:
+		points = room.rules.weaponArea(target)
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("%s has nothing to shoot at there", weapon)
+	}
+
+	shooter.weapons[weapon]--
+	room.announce(USE_WEAPON, StocUseWeapon{
+		Role:      shooter.role,
+		Weapon:    weapon,
+		Position:  StocPoint{X: target.x, Y: target.y},
+		Direction: direction,
+		Remaining: shooter.weapons[weapon],
+	})
+	room.logInfo("%s has used %s at %+v", shooter.name, weapon, target)
+
+	keepTurn, mineHit := false, false
+	switch weapon {
+	case WEAPON_BOMB:
+		for _, point := range points {
+			switch room.shoot(shooter, point) {
+			case SHOT_HIT, SHOT_SUNK:
+				keepTurn = true
+			case SHOT_MINE:
+				mineHit = true
+			}
+		}
+	case WEAPON_TORPEDO:
+		for _, point := range points {
+			if result := room.shoot(shooter, point); result != SHOT_MISS {
+				keepTurn = result == SHOT_HIT || result == SHOT_SUNK
+				mineHit = result == SHOT_MINE
+				break
+			}
+		}
+	case WEAPON_SONAR:
+		room.sonarScan(shooter, target, points)
+	}
+
+	if enemy.teamDefeated() {
+		room.finish(shooter, false)
+	} else if keepTurn && !mineHit { // Mine costs the turn whatever else is hit, as a single shot at it does
+		room.startTurnClock()
+		room.scheduleBotMove()
+	} else {
+		room.switchTurn()
+	}
+	return nil
+}
+
+// sonarScan shows shooter and spectators if there are ship cells in the area which are not hit yet
+func (room *Room) sonarScan(shooter *Player, target Vec2, points []Vec2) {
+	enemy := shooter.enemy()
+	type_ := SONAR_CLEAR
+	for _, point := range points {
+		if entity := enemy.entityAt(point); entity != nil && entity.ship() && !enemy.isAlreadyShotAt(point) {
+			type_ = SONAR_CONTACT
+			break
+		}
+	}
+
+	sendEvent := newStocAddEntity(enemy.role, type_, target, HORIZONTAL)
+	shooter.send(ADD_ENTITY, sendEvent)
+	room.announceToSpectators(ADD_ENTITY, sendEvent, false)
+}
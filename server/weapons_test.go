@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newWeaponsTestRoom(t *testing.T) *Room {
+	rules := defaultRules()
+	rules.Weapons = map[WeaponType]int{WEAPON_BOMB: 1, WEAPON_TORPEDO: 2, WEAPON_SONAR: 1}
+	return newTestPlayingRoom(t, newTestConfig(t), rules)
+}
+
+func TestWeapons(t *testing.T) {
+	room := newWeaponsTestRoom(t)
+	defer room.destroy()
+	primary, secondary := room.primary, room.secondary
+
+	// Sonar: four-deck ship is at 1:1..4:1
+	if err := room.useWeapon(primary, WEAPON_SONAR, Vec2{x: 2, y: 2}, HORIZONTAL); err != nil {
+		t.Fatalf("Could not use sonar: %s", err)
+	}
+	if len(secondary.shotPoints) != 0 || room.turn != SECONDARY {
+		t.Errorf("Sonar has damaged enemy or has not passed the turn")
+	}
+	if room.useWeapon(secondary, WEAPON_SONAR, Vec2{x: 2, y: 2}, HORIZONTAL) != nil {
+		t.Fatalf("Could not use sonar")
+	}
+	if err := room.useWeapon(primary, WEAPON_SONAR, Vec2{x: 2, y: 2}, HORIZONTAL); err == nil {
+		t.Errorf("Sonar was used more times than allowed")
+	}
+
+	// Bomb: hits four-deck ship at 1:1, 2:1 and three-deck ship at 1:3, 2:3
+	if err := room.useWeapon(primary, WEAPON_BOMB, Vec2{x: 1, y: 2}, HORIZONTAL); err != nil {
+		t.Fatalf("Could not use bomb: %s", err)
+	}
+	if primary.shots != 6 || primary.hits != 4 || room.turn != PRIMARY {
+		t.Errorf("Unexpected bomb result: %d shots, %d hits, turn of %d", primary.shots, primary.hits, room.turn)
+	}
+
+	// Torpedo: runs along row 5 and stops at two-deck ship at 1:5
+	if err := room.useWeapon(primary, WEAPON_TORPEDO, Vec2{x: 9, y: 5}, HORIZONTAL); err != nil {
+		t.Fatalf("Could not use torpedo: %s", err)
+	}
+	if primary.shots != 7 || !secondary.isAlreadyShotAt(Vec2{x: 1, y: 5}) || secondary.isAlreadyShotAt(Vec2{x: 3, y: 5}) {
+		t.Errorf("Torpedo has not stopped at the first ship")
+	}
+	// Column 10 is empty, so torpedo misses everything and passes the turn
+	if err := room.useWeapon(primary, WEAPON_TORPEDO, Vec2{x: 10, y: 1}, VERTICAL); err != nil {
+		t.Fatalf("Could not use torpedo: %s", err)
+	}
+	if primary.shots != 17 || room.turn != SECONDARY {
+		t.Errorf("Torpedo has not missed the whole column: %d shots", primary.shots)
+	}
+}
+
+func TestInvalidWeapons(t *testing.T) {
+	for _, invalid := range []struct {
+		weapons map[WeaponType]int
+		salvo   bool
+	}{
+		{map[WeaponType]int{"laser": 1}, false},
+		{map[WeaponType]int{WEAPON_BOMB: MAX_WEAPON_USES + 1}, false},
+		{map[WeaponType]int{WEAPON_BOMB: 1}, true},
+	} {
+		rules := defaultRules()
+		rules.Weapons, rules.Salvo = invalid.weapons, invalid.salvo
+		if rules.validate() == nil {
+			t.Errorf("Invalid weapons were accepted: %+v", invalid)
+		}
+	}
+}
+
+func TestWeaponsSnapshotForSpectator(t *testing.T) {
+	room := newWeaponsTestRoom(t)
+	defer room.destroy()
+	room.cfg.SpectatorRevealDelay = 50 * time.Millisecond
+
+	spectator := newTestPlayer(room.cfg, "spectator")
+	events := connectTestPlayer(t, spectator)
+	room.addSpectator(spectator, true)
+	nextTestEvent(t, events, SPECTATE_ROOM)
+
+	// Snapshot is still queued while weapons are used, so it must not see the change
+	if err := room.useWeapon(room.primary, WEAPON_SONAR, Vec2{x: 2, y: 2}, HORIZONTAL); err != nil {
+		t.Fatalf("Could not use sonar: %s", err)
+	}
+
+	snapshot := StocStateSnapshot{}
+	if err := json.Unmarshal(nextTestEvent(t, events, STATE_SNAPSHOT).Data, &snapshot); err != nil {
+		t.Fatalf("Could not decode snapshot: %s", err)
+	}
+	if sonar := snapshot.Battlefields[0].Weapons[WEAPON_SONAR]; sonar != 1 {
+		t.Errorf("Snapshot has %d sonars left, expected 1 as it was queued before sonar was used", sonar)
+	}
+}
+
+func TestWeaponsMine(t *testing.T) {
+	for _, effect := range []MineEffect{MINE_SKIP_TURN, MINE_REVEAL_CELL} {
+		rules := defaultRules()
+		rules.Mines = 1
+		rules.MineEffect = effect
+		rules.Weapons = map[WeaponType]int{WEAPON_BOMB: 1}
+
+		room := newTestRoom(t, newTestConfig(t), rules)
+		for _, player := range room.players() {
+			player.placeEntity(MINE, Vec2{x: 6, y: 1}, HORIZONTAL)
+			placeTestFleet(t, player)
+		}
+		if !room.startPlaying() {
+			t.Fatalf("Could not start playing")
+		}
+
+		// Bomb hits four-deck ship at 4:1 and mine at 6:1
+		if err := room.useWeapon(room.primary, WEAPON_BOMB, Vec2{x: 5, y: 1}, HORIZONTAL); err != nil {
+			t.Fatalf("Could not use bomb: %s", err)
+		}
+		if room.primary.hits != 1 || room.turn != SECONDARY {
+			t.Errorf("Bomb which has hit mine (%s) has kept the turn: %d hits, turn of %d", effect, room.primary.hits, room.turn)
+		}
+		room.destroy()
+	}
+}